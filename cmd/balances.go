@@ -39,7 +39,7 @@ func balancesListCMD() *cobra.Command {
 
 			fmt.Printf("Accounts Balances at %x:\n", state.LatestBlockHash())
 			for account, balance := range state.Balances {
-				fmt.Printf("%s: %d\n", account, balance)
+				fmt.Printf("%s: %d (next nonce %d, fees earned %d)\n", account, balance, state.NextNonce(account), state.Fees[account])
 			}
 		},
 	}
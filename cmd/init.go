@@ -0,0 +1,35 @@
+package main
+
+import (
+	"blockchain-sample/database"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+)
+
+var flagGenesis = "genesis"
+
+func initCMD() *cobra.Command {
+	var initCMD = &cobra.Command{
+		Use:   "init",
+		Short: "Initializes a data dir with the given genesis file",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir, _ := cmd.Flags().GetString(flagDataDir)
+			genesisPath, _ := cmd.Flags().GetString(flagGenesis)
+
+			content, err := ioutil.ReadFile(genesisPath)
+			exitOnErr(err)
+
+			genesisHash, err := database.InitDataDir(dataDir, content)
+			exitOnErr(err)
+
+			fmt.Printf("Initialized %s with genesis %x\n", dataDir, genesisHash)
+		},
+	}
+
+	addDefaultRequiredFlags(initCMD)
+	initCMD.Flags().String(flagGenesis, "", "path of the genesis json file to install")
+	initCMD.MarkFlagRequired(flagGenesis)
+	return initCMD
+}
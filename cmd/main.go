@@ -1,6 +1,9 @@
 package main
 
 import (
+	// registers the consensus engines a genesis can pick,
+	// every command loading a state depends on them
+	_ "blockchain-sample/consensus"
 	"fmt"
 	"os"
 
@@ -10,6 +13,15 @@ import (
 var flagDataDir = "dataDir"
 var flagPort = "port"
 var flagIP = "ip"
+var flagProducer = "producer"
+var flagProduceInterval = "produceInterval"
+var flagProduceSize = "produceSize"
+var flagCheckpoint = "checkpoint"
+var flagMaxReorgDepth = "maxReorgDepth"
+var flagMempoolMaxTxns = "mempoolMaxTxns"
+var flagMempoolMaxBytes = "mempoolMaxBytes"
+var flagMempoolMaxPerAccount = "mempoolMaxPerAccount"
+var flagMempoolExpiry = "mempoolExpiry"
 
 func main() {
 	var paisaCMD = &cobra.Command{
@@ -18,10 +30,12 @@ func main() {
 		Run:   func(cmd *cobra.Command, args []string) {},
 	}
 
+	paisaCMD.AddCommand(initCMD())
 	paisaCMD.AddCommand(runCmd())
 	paisaCMD.AddCommand(versionCMD)
 	paisaCMD.AddCommand(migrateCMD())
 	paisaCMD.AddCommand(balancesCMD())
+	paisaCMD.AddCommand(walletCMD())
 
 	err := paisaCMD.Execute()
 	if err != nil {
@@ -2,80 +2,45 @@ package main
 
 import (
 	"blockchain-sample/database"
+	"encoding/json"
 	"fmt"
-	"os"
-	"time"
+	"io/ioutil"
 
 	"github.com/spf13/cobra"
 )
 
+var flagNewDataDir = "newDataDir"
+var flagAccounts = "accounts"
+
 var migrateCMD = func() *cobra.Command {
 	var migrateCMD = &cobra.Command{
 		Use:   "migrate",
-		Short: "Migrates the blockchain databse according to new business rule.",
+		Short: "Migrates a blockchain with legacy name accounts to a new genesis with addresses.",
+		Long: "Migrates a blockchain with legacy name accounts to a new genesis with addresses. " +
+			"The accounts file maps each legacy name to an address, e.g. {\"dibek\": \"paisa1...\"}. " +
+			"The balances at the last legacy block are allocated to the addresses in the new genesis.",
 		Run: func(cmd *cobra.Command, args []string) {
 			dataDir, _ := cmd.Flags().GetString(flagDataDir)
-			state, err := database.NewStateFromDisk(dataDir)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-			defer state.Close()
-
-			block0 := database.NewBlock(
-				database.Hash{},
-				0,
-				uint64(time.Now().Unix()),
-				[]database.Txn{
-					database.NewTxn("dibek", "dibek", 3, ""),
-					database.NewTxn("dibek", "dibek", 700, "reward"),
-				},
-			)
-
-			block0hash, err := state.AddBlock(block0)
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
-
-			block1 := database.NewBlock(
-				block0hash,
-				1,
-				uint64(time.Now().Unix()),
-				[]database.Txn{
-					database.NewTxn("dibek", "nishan", 2000, ""),
-					database.NewTxn("dibek", "dibek", 100, "reward"),
-					database.NewTxn("nishan", "dibek", 1, ""),
-					database.NewTxn("nishan", "sasim", 1000, ""),
-					database.NewTxn("nishan", "dibek", 50, ""),
-					database.NewTxn("dibek", "dibek", 600, "reward"),
-				},
-			)
+			newDataDir, _ := cmd.Flags().GetString(flagNewDataDir)
+			accountsPath, _ := cmd.Flags().GetString(flagAccounts)
 
-			block1hash, err := state.AddBlock(block1)
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
+			content, err := ioutil.ReadFile(accountsPath)
+			exitOnErr(err)
 
-			block2 := database.NewBlock(
-				block1hash,
-				2,
-				uint64(time.Now().Unix()),
-				[]database.Txn{
-					database.NewTxn("dibek", "dibek", 2400, "reward"),
-				},
-			)
+			accounts := make(map[string]database.Account)
+			exitOnErr(json.Unmarshal(content, &accounts))
 
-			_, err = state.AddBlock(block2)
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
+			genesisHash, err := database.MigrateLegacy(dataDir, newDataDir, accounts)
+			exitOnErr(err)
 
+			fmt.Printf("Initialized %s with migrated genesis %x\n", newDataDir, genesisHash)
 		},
 	}
 	addDefaultRequiredFlags(migrateCMD)
+	migrateCMD.Flags().String(flagNewDataDir, "", "absolute path of the data dir to create with the migrated genesis")
+	migrateCMD.MarkFlagRequired(flagNewDataDir)
+	migrateCMD.Flags().String(flagAccounts, "", "path of the json file mapping legacy names to addresses")
+	migrateCMD.MarkFlagRequired(flagAccounts)
 
 	return migrateCMD
 }
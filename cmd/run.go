@@ -1,7 +1,10 @@
 package main
 
 import (
+	"blockchain-sample/database"
 	"blockchain-sample/node"
+	"blockchain-sample/wallet"
+	"crypto/ed25519"
 	"fmt"
 	"os"
 
@@ -18,8 +21,41 @@ func runCmd() *cobra.Command {
 			port, _ := cmd.Flags().GetUint64(flagPort)
 			ip, _ := cmd.Flags().GetString(flagIP)
 
+			// unlock the key of the producer in the node's keystore
+			var producerKey ed25519.PrivateKey
+			if producer, _ := cmd.Flags().GetString(flagProducer); producer != "" {
+				account, err := database.NewAccount(producer)
+				exitOnErr(err)
+
+				passphrase := getPassphrase("Please enter the passphrase of the producer", false)
+				producerKey, err = wallet.LoadKey(dataDir, account, passphrase)
+				exitOnErr(err)
+			}
+
+			producerConfig := node.ProducerConfig{}
+			producerConfig.Interval, _ = cmd.Flags().GetDuration(flagProduceInterval)
+			producerConfig.BlockSize, _ = cmd.Flags().GetInt(flagProduceSize)
+			if producerConfig.Interval <= 0 {
+				exitOnErr(fmt.Errorf("--%s must be greater than 0", flagProduceInterval))
+			}
+
+			finality := database.Finality{}
+			finality.MaxReorgDepth, _ = cmd.Flags().GetUint64(flagMaxReorgDepth)
+			checkpoints, _ := cmd.Flags().GetStringArray(flagCheckpoint)
+			for _, value := range checkpoints {
+				checkpoint, err := database.ParseCheckpoint(value)
+				exitOnErr(err)
+				finality.Checkpoints = append(finality.Checkpoints, checkpoint)
+			}
+
+			mempoolLimits := database.MempoolLimits{}
+			mempoolLimits.MaxTxns, _ = cmd.Flags().GetInt(flagMempoolMaxTxns)
+			mempoolLimits.MaxBytes, _ = cmd.Flags().GetInt(flagMempoolMaxBytes)
+			mempoolLimits.MaxPerAccount, _ = cmd.Flags().GetInt(flagMempoolMaxPerAccount)
+			mempoolLimits.Expiry, _ = cmd.Flags().GetDuration(flagMempoolExpiry)
+
 			bootstrap := node.NewPeerNode("40.71.208.186", 8080, true, true)
-			n := node.New(dataDir, ip, port, *bootstrap)
+			n := node.New(dataDir, ip, port, producerKey, producerConfig, *bootstrap, finality, mempoolLimits)
 			err := n.Run()
 			if err != nil {
 				fmt.Println(err)
@@ -30,5 +66,14 @@ func runCmd() *cobra.Command {
 	addDefaultRequiredFlags(runCMD)
 	runCMD.Flags().Uint64(flagPort, node.DefaultHttpPort, "port to run the node on")
 	runCMD.Flags().String(flagIP, node.DefaultIP, "ip to run the node on")
+	runCMD.Flags().String(flagProducer, "", "keystore account to produce and sign blocks with")
+	runCMD.Flags().Duration(flagProduceInterval, node.DefaultProduceInterval, "how often pending txns are packed into a block")
+	runCMD.Flags().Int(flagProduceSize, 0, "number of pending txns which trigger a block right away, 0 for a full block")
+	runCMD.Flags().StringArray(flagCheckpoint, nil, "trusted block in the format number:hash, repeat for each checkpoint")
+	runCMD.Flags().Uint64(flagMaxReorgDepth, 0, "maximum number of blocks a reorg may roll back, 0 for no limit")
+	runCMD.Flags().Int(flagMempoolMaxTxns, database.DefaultMempoolLimits.MaxTxns, "maximum number of pending txns, 0 for no limit")
+	runCMD.Flags().Int(flagMempoolMaxBytes, database.DefaultMempoolLimits.MaxBytes, "maximum size of the pending txns in bytes, 0 for no limit")
+	runCMD.Flags().Int(flagMempoolMaxPerAccount, database.DefaultMempoolLimits.MaxPerAccount, "maximum number of pending txns per sender, 0 for no limit")
+	runCMD.Flags().Duration(flagMempoolExpiry, database.DefaultMempoolLimits.Expiry, "how long a txn may stay pending, 0 to never expire")
 	return runCMD
 }
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"blockchain-sample/database"
+	"blockchain-sample/wallet"
+	"bufio"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var flagAccount = "account"
+var flagKeyFile = "keyFile"
+
+// stdin is shared by all passphrase prompts so that
+// buffered input is not lost between prompts
+var stdin = bufio.NewReader(os.Stdin)
+
+func walletCMD() *cobra.Command {
+	var walletCMD = &cobra.Command{
+		Use:   "wallet",
+		Short: "Manage accounts and keys in the keystore",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return incorrectUsageErr()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	walletCMD.AddCommand(walletNewCMD())
+	walletCMD.AddCommand(walletListCMD())
+	walletCMD.AddCommand(walletImportCMD())
+	walletCMD.AddCommand(walletExportCMD())
+	walletCMD.AddCommand(walletChangePassphraseCMD())
+	walletCMD.AddCommand(walletMultisigCMD())
+	walletCMD.AddCommand(walletSignCMD())
+	walletCMD.AddCommand(walletCombineCMD())
+	walletCMD.AddCommand(walletNewMnemonicCMD())
+	walletCMD.AddCommand(walletRestoreCMD())
+	walletCMD.AddCommand(walletDeriveCMD())
+
+	return walletCMD
+}
+
+func walletNewCMD() *cobra.Command {
+	var walletNewCMD = &cobra.Command{
+		Use:   "new",
+		Short: "Creates a new account with a passphrase encrypted key",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir, _ := cmd.Flags().GetString(flagDataDir)
+
+			passphrase := getPassphrase("Please enter a passphrase to encrypt the new key", true)
+			account, err := wallet.NewKeystoreAccount(dataDir, passphrase)
+			exitOnErr(err)
+
+			fmt.Printf("New account created: %s\n", account)
+		},
+	}
+
+	addDefaultRequiredFlags(walletNewCMD)
+	return walletNewCMD
+}
+
+func walletListCMD() *cobra.Command {
+	var walletListCMD = &cobra.Command{
+		Use:   "list",
+		Short: "Lists all accounts in the keystore",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir, _ := cmd.Flags().GetString(flagDataDir)
+
+			accounts, err := wallet.ListAccounts(dataDir)
+			exitOnErr(err)
+
+			fmt.Printf("Accounts in %s:\n", wallet.GetKeystoreDirPath(dataDir))
+			for _, account := range accounts {
+				keyFile, err := wallet.GetKeyFile(dataDir, account)
+				exitOnErr(err)
+				if keyFile.HDIndex != nil {
+					fmt.Printf("%s (public key %x, derived at %s)\n", account, []byte(keyFile.PubKey), wallet.HDPath(*keyFile.HDIndex))
+					continue
+				}
+				fmt.Printf("%s (public key %x)\n", account, []byte(keyFile.PubKey))
+			}
+		},
+	}
+
+	addDefaultRequiredFlags(walletListCMD)
+	return walletListCMD
+}
+
+func walletImportCMD() *cobra.Command {
+	var walletImportCMD = &cobra.Command{
+		Use:   "import",
+		Short: "Imports a hex encoded private key into the keystore",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir, _ := cmd.Flags().GetString(flagDataDir)
+			keyFile, _ := cmd.Flags().GetString(flagKeyFile)
+
+			content, err := ioutil.ReadFile(keyFile)
+			exitOnErr(err)
+
+			privKey, err := parsePrivateKey(strings.TrimSpace(string(content)))
+			exitOnErr(err)
+
+			passphrase := getPassphrase("Please enter a passphrase to encrypt the imported key", true)
+			account, err := wallet.ImportKey(dataDir, privKey, passphrase)
+			exitOnErr(err)
+
+			fmt.Printf("Imported account: %s\n", account)
+		},
+	}
+
+	addDefaultRequiredFlags(walletImportCMD)
+	walletImportCMD.Flags().String(flagKeyFile, "", "path of the file holding the hex encoded private key")
+	walletImportCMD.MarkFlagRequired(flagKeyFile)
+	return walletImportCMD
+}
+
+func walletExportCMD() *cobra.Command {
+	var walletExportCMD = &cobra.Command{
+		Use:   "export",
+		Short: "Prints the hex encoded private key of an account",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir, _ := cmd.Flags().GetString(flagDataDir)
+			account, err := database.NewAccount(getAccountFlag(cmd))
+			exitOnErr(err)
+
+			passphrase := getPassphrase("Please enter the passphrase of the account", false)
+			privKey, err := wallet.LoadKey(dataDir, account, passphrase)
+			exitOnErr(err)
+
+			fmt.Println(hex.EncodeToString(privKey.Seed()))
+		},
+	}
+
+	addDefaultRequiredFlags(walletExportCMD)
+	addAccountRequiredFlag(walletExportCMD)
+	return walletExportCMD
+}
+
+func walletChangePassphraseCMD() *cobra.Command {
+	var walletChangePassphraseCMD = &cobra.Command{
+		Use:   "change-passphrase",
+		Short: "Re-encrypts the key of an account with a new passphrase",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir, _ := cmd.Flags().GetString(flagDataDir)
+			account, err := database.NewAccount(getAccountFlag(cmd))
+			exitOnErr(err)
+
+			oldPassphrase := getPassphrase("Please enter the current passphrase of the account", false)
+			newPassphrase := getPassphrase("Please enter the new passphrase", true)
+
+			err = wallet.ChangePassphrase(dataDir, account, oldPassphrase, newPassphrase)
+			exitOnErr(err)
+
+			fmt.Printf("Passphrase of %s changed\n", account)
+		},
+	}
+
+	addDefaultRequiredFlags(walletChangePassphraseCMD)
+	addAccountRequiredFlag(walletChangePassphraseCMD)
+	return walletChangePassphraseCMD
+}
+
+func addAccountRequiredFlag(cmd *cobra.Command) {
+	cmd.Flags().String(flagAccount, "", "account in the keystore")
+	cmd.MarkFlagRequired(flagAccount)
+}
+
+func getAccountFlag(cmd *cobra.Command) string {
+	account, _ := cmd.Flags().GetString(flagAccount)
+	return account
+}
+
+// getPassphrase prompts on stderr for a passphrase read from stdin,
+// asking for it twice when confirm is set
+func getPassphrase(prompt string, confirm bool) string {
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	passphrase := readSecret()
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		if readSecret() != passphrase {
+			exitOnErr(fmt.Errorf("passphrases do not match"))
+		}
+	}
+
+	return passphrase
+}
+
+// readSecret reads a passphrase or mnemonic without echoing it when
+// stdin is a terminal, piped secrets are read line by line
+func readSecret() string {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return readLine()
+	}
+
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		exitOnErr(fmt.Errorf("unable to read from stdin: %s", err))
+	}
+	return string(secret)
+}
+
+func readLine() string {
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		exitOnErr(fmt.Errorf("unable to read from stdin: %s", err))
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+// parsePrivateKey accepts a hex encoded ed25519 seed or full private key
+func parsePrivateKey(value string) (ed25519.PrivateKey, error) {
+	key, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("private key must be hex encoded: %s", err)
+	}
+
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.NewKeyFromSeed(key[:ed25519.SeedSize]), nil
+	default:
+		return nil, fmt.Errorf("private key must be %d or %d bytes, not %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(key))
+	}
+}
+
+func exitOnErr(err error) {
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"blockchain-sample/wallet"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var flagIndex = "index"
+var flagCount = "count"
+
+func walletNewMnemonicCMD() *cobra.Command {
+	var walletNewMnemonicCMD = &cobra.Command{
+		Use:   "new-mnemonic",
+		Short: "Creates the keystore seed from a new mnemonic and derives the first account",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir, _ := cmd.Flags().GetString(flagDataDir)
+
+			mnemonic, err := wallet.NewMnemonic()
+			exitOnErr(err)
+
+			passphrase := getPassphrase("Please enter a passphrase to encrypt the seed and derived keys", true)
+			exitOnErr(wallet.SetSeed(dataDir, mnemonic, passphrase))
+
+			account, err := wallet.DeriveAccount(dataDir, 0, passphrase)
+			exitOnErr(err)
+
+			fmt.Println("Write down the mnemonic and keep it safe, it recovers every derived account:")
+			fmt.Println(mnemonic)
+			fmt.Printf("Derived account %s at %s\n", account, wallet.HDPath(0))
+		},
+	}
+
+	addDefaultRequiredFlags(walletNewMnemonicCMD)
+	return walletNewMnemonicCMD
+}
+
+func walletRestoreCMD() *cobra.Command {
+	var walletRestoreCMD = &cobra.Command{
+		Use:   "restore",
+		Short: "Restores the keystore seed from a mnemonic and derives its accounts",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir, _ := cmd.Flags().GetString(flagDataDir)
+			count, _ := cmd.Flags().GetUint32(flagCount)
+
+			fmt.Fprint(os.Stderr, "Please enter the mnemonic: ")
+			mnemonic := readSecret()
+
+			passphrase := getPassphrase("Please enter a passphrase to encrypt the seed and derived keys", true)
+			exitOnErr(wallet.SetSeed(dataDir, mnemonic, passphrase))
+
+			for i := uint32(0); i < count; i++ {
+				account, err := wallet.DeriveAccount(dataDir, i, passphrase)
+				exitOnErr(err)
+				fmt.Printf("Derived account %s at %s\n", account, wallet.HDPath(i))
+			}
+		},
+	}
+
+	addDefaultRequiredFlags(walletRestoreCMD)
+	walletRestoreCMD.Flags().Uint32(flagCount, 1, "number of accounts to derive")
+	return walletRestoreCMD
+}
+
+func walletDeriveCMD() *cobra.Command {
+	var walletDeriveCMD = &cobra.Command{
+		Use:   "derive",
+		Short: "Derives the account with the given index from the keystore seed",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir, _ := cmd.Flags().GetString(flagDataDir)
+			index, _ := cmd.Flags().GetUint32(flagIndex)
+
+			passphrase := getPassphrase("Please enter the passphrase of the seed", false)
+			account, err := wallet.DeriveAccount(dataDir, index, passphrase)
+			exitOnErr(err)
+
+			fmt.Printf("Derived account %s at %s\n", account, wallet.HDPath(index))
+		},
+	}
+
+	addDefaultRequiredFlags(walletDeriveCMD)
+	walletDeriveCMD.Flags().Uint32(flagIndex, 0, "index of the account to derive")
+	walletDeriveCMD.MarkFlagRequired(flagIndex)
+	return walletDeriveCMD
+}
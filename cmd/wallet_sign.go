@@ -0,0 +1,156 @@
+package main
+
+import (
+	"blockchain-sample/database"
+	"blockchain-sample/wallet"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var flagThreshold = "threshold"
+var flagPubKeys = "pubKeys"
+var flagTxn = "txn"
+var flagOut = "out"
+
+func walletMultisigCMD() *cobra.Command {
+	var walletMultisigCMD = &cobra.Command{
+		Use:   "multisig",
+		Short: "Prints the account of an M-of-N multisig",
+		Run: func(cmd *cobra.Command, args []string) {
+			threshold, _ := cmd.Flags().GetUint(flagThreshold)
+			hexKeys, _ := cmd.Flags().GetStringSlice(flagPubKeys)
+
+			pubKeys := make([]database.Bytes, len(hexKeys))
+			for i, hexKey := range hexKeys {
+				exitOnErr(pubKeys[i].UnmarshalText([]byte(hexKey)))
+			}
+
+			multisig, err := database.NewMultisigAccount(threshold, pubKeys)
+			exitOnErr(err)
+
+			multisigJson, err := json.MarshalIndent(multisig, "", "  ")
+			exitOnErr(err)
+
+			fmt.Printf("Multisig account: %s\n", multisig.Account())
+			fmt.Printf("Add the multisig to txns sent from it:\n%s\n", multisigJson)
+		},
+	}
+
+	walletMultisigCMD.Flags().Uint(flagThreshold, 0, "number of signatures required")
+	walletMultisigCMD.MarkFlagRequired(flagThreshold)
+	walletMultisigCMD.Flags().StringSlice(flagPubKeys, nil, "comma separated hex encoded public keys of the owners")
+	walletMultisigCMD.MarkFlagRequired(flagPubKeys)
+	return walletMultisigCMD
+}
+
+func walletSignCMD() *cobra.Command {
+	var walletSignCMD = &cobra.Command{
+		Use:   "sign",
+		Short: "Signs a txn file with the key of an account",
+		Long: "Signs a txn file with the key of an account. " +
+			"A txn from a multisig account gets a partial signature added, " +
+			"partial signatures of several owners are merged with combine.",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir, _ := cmd.Flags().GetString(flagDataDir)
+			account, err := database.NewAccount(getAccountFlag(cmd))
+			exitOnErr(err)
+			txnPath, _ := cmd.Flags().GetString(flagTxn)
+			out, _ := cmd.Flags().GetString(flagOut)
+
+			txn, err := readTxnFile(txnPath)
+			exitOnErr(err)
+
+			passphrase := getPassphrase("Please enter the passphrase of the account", false)
+			privKey, err := wallet.LoadKey(dataDir, account, passphrase)
+			exitOnErr(err)
+
+			if txn.Multisig != nil {
+				exitOnErr(txn.AddSig(privKey))
+			} else {
+				if txn.From != account {
+					exitOnErr(fmt.Errorf("txn is sent from %s, not %s", txn.From, account))
+				}
+				txn, err = database.NewSignedTxn(txn.Txn, privKey)
+				exitOnErr(err)
+			}
+
+			exitOnErr(writeTxnFile(out, txn))
+		},
+	}
+
+	addDefaultRequiredFlags(walletSignCMD)
+	addAccountRequiredFlag(walletSignCMD)
+	addTxnFlags(walletSignCMD)
+	return walletSignCMD
+}
+
+func walletCombineCMD() *cobra.Command {
+	var walletCombineCMD = &cobra.Command{
+		Use:   "combine",
+		Short: "Combines the partial signatures of multisig txn files",
+		Run: func(cmd *cobra.Command, args []string) {
+			txnPaths, _ := cmd.Flags().GetStringArray(flagTxn)
+			out, _ := cmd.Flags().GetString(flagOut)
+
+			combined, err := readTxnFile(txnPaths[0])
+			exitOnErr(err)
+
+			for _, txnPath := range txnPaths[1:] {
+				txn, err := readTxnFile(txnPath)
+				exitOnErr(err)
+				exitOnErr(combined.CombineSigs(txn))
+			}
+
+			if err := combined.IsAuthentic(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+			}
+
+			exitOnErr(writeTxnFile(out, combined))
+		},
+	}
+
+	walletCombineCMD.Flags().StringArray(flagTxn, nil, "path of a partially signed txn file, repeat for each file")
+	walletCombineCMD.MarkFlagRequired(flagTxn)
+	walletCombineCMD.Flags().String(flagOut, "", "path to write the combined txn to, defaults to stdout")
+	return walletCombineCMD
+}
+
+func addTxnFlags(cmd *cobra.Command) {
+	cmd.Flags().String(flagTxn, "", "path of the json txn file")
+	cmd.MarkFlagRequired(flagTxn)
+	cmd.Flags().String(flagOut, "", "path to write the signed txn to, defaults to stdout")
+}
+
+// readTxnFile reads a txn in the json format accepted by /txn/add
+func readTxnFile(path string) (database.SignedTxn, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return database.SignedTxn{}, err
+	}
+
+	var txn database.SignedTxn
+	if err := json.Unmarshal(content, &txn); err != nil {
+		return database.SignedTxn{}, fmt.Errorf("unable to unmarshal txn %s: %s", path, err)
+	}
+
+	return txn, nil
+}
+
+// writeTxnFile writes the txn to the path, or stdout if path is empty
+func writeTxnFile(path string, txn database.SignedTxn) error {
+	txnJson, err := json.MarshalIndent(txn, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		fmt.Println(string(txnJson))
+		return nil
+	}
+
+	return ioutil.WriteFile(path, append(txnJson, '\n'), 0600)
+}
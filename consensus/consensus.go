@@ -0,0 +1,49 @@
+// Package consensus implements the engines a chain can pick in its genesis:
+// instant blocks for development, proof of work and proof of authority.
+package consensus
+
+import (
+	"blockchain-sample/database"
+)
+
+// Engine implements the consensus rules of a chain, it is declared in
+// the database so that the state does not depend on the engines
+type Engine = database.Engine
+
+// ChainReader is the chain a block is built on, as read by the state
+type ChainReader = database.ChainReader
+
+const (
+	EngineInstant          = "instant"
+	EngineProofOfWork      = "pow"
+	EngineProofOfAuthority = "poa"
+)
+
+// the engines are registered when a binary imports the package
+func init() {
+	database.RegisterEngine(EngineInstant, NewInstant)
+	database.RegisterEngine(EngineProofOfWork, NewProofOfWork)
+	database.RegisterEngine(EngineProofOfAuthority, NewProofOfAuthority)
+}
+
+// nextBlockTime returns the earliest valid time from now of the next block.
+// It is later than the median time of the latest blocks and the first
+// block is not older than the genesis.
+func nextBlockTime(chain ChainReader, now uint64) uint64 {
+	if chain.NextBlockNumber() == 0 {
+		if now < chain.GenesisTime() {
+			return chain.GenesisTime()
+		}
+		return now
+	}
+
+	if median := chain.MedianTimePast(); now <= median {
+		return median + 1
+	}
+	return now
+}
+
+// blockReward returns the block reward scheduled in genesis
+func blockReward(chain ChainReader, header database.BlockHeader) uint {
+	return database.BlockReward(header.Number, chain.Supply(), chain.Consensus())
+}
@@ -0,0 +1,50 @@
+package consensus
+
+import (
+	"blockchain-sample/database"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+)
+
+// Instant is a development engine which seals a block as soon
+// as it is produced. Any producer can add blocks at any time.
+type Instant struct{}
+
+// NewInstant returns the instant engine
+func NewInstant(params database.ConsensusParams) (Engine, error) {
+	if len(params.Signers) > 0 {
+		return nil, fmt.Errorf("consensus signers are only used with proof of authority")
+	}
+	return Instant{}, nil
+}
+
+// PrepareHeader dates the block now with a difficulty of 1
+func (Instant) PrepareHeader(chain ChainReader, header *database.BlockHeader, now uint64) error {
+	header.Time = nextBlockTime(chain, now)
+	header.Difficulty = 1
+	return nil
+}
+
+// Seal signs the block
+func (Instant) Seal(ctx context.Context, b database.Block, privKey ed25519.PrivateKey) (database.Block, error) {
+	return database.SignBlock(b, privKey)
+}
+
+// VerifySeal accepts every signed block
+func (Instant) VerifySeal(b database.Block) error {
+	return nil
+}
+
+// VerifyHeader checks that every block has a difficulty of 1
+func (Instant) VerifyHeader(chain ChainReader, header database.BlockHeader) error {
+	if header.Difficulty != 1 {
+		return fmt.Errorf("block %d has difficulty %d, expected 1", header.Number, header.Difficulty)
+	}
+	return nil
+}
+
+// Finalize returns the block reward scheduled in genesis
+func (Instant) Finalize(chain ChainReader, header database.BlockHeader) uint {
+	return blockReward(chain, header)
+}
@@ -0,0 +1,131 @@
+package consensus
+
+import (
+	"blockchain-sample/database"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+)
+
+// ProofOfAuthority is an engine in which authorized signers take turns
+// producing blocks. Time is divided into slots of the block interval
+// since the genesis and each slot belongs to one signer in turn.
+type ProofOfAuthority struct {
+	params database.ConsensusParams
+}
+
+// NewProofOfAuthority returns the proof of authority engine
+func NewProofOfAuthority(params database.ConsensusParams) (Engine, error) {
+	if len(params.Signers) == 0 {
+		return nil, fmt.Errorf("consensus signers must list at least one signer")
+	}
+
+	signers := make(map[database.Account]bool)
+	for _, signer := range params.Signers {
+		if err := signer.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid consensus signer: %s", err)
+		}
+		if signers[signer] {
+			return nil, fmt.Errorf("consensus signer %s is listed twice", signer)
+		}
+		signers[signer] = true
+	}
+
+	return ProofOfAuthority{params}, nil
+}
+
+// PrepareHeader dates the block at the start of the next slot
+// of the producer after the latest block, or now if the current
+// slot is the producer's. Blocks are not mined and have difficulty 1.
+func (e ProofOfAuthority) PrepareHeader(chain ChainReader, header *database.BlockHeader, now uint64) error {
+	signers := chain.Signers()
+	if !isSigner(signers, header.Producer) {
+		return fmt.Errorf("%s is not an authorized signer", header.Producer)
+	}
+
+	slot := e.slot(chain, now)
+	if chain.NextBlockNumber() > 0 {
+		if parentSlot := e.slot(chain, chain.LatestBlock().Header.Time); slot <= parentSlot {
+			slot = parentSlot + 1
+		}
+	}
+
+	for slotSigner(signers, slot) != header.Producer {
+		slot++
+	}
+
+	header.Time = chain.GenesisTime() + slot*e.params.BlockInterval
+	if header.Time < now {
+		header.Time = now
+	}
+	header.Difficulty = 1
+	return nil
+}
+
+// Seal signs the block
+func (e ProofOfAuthority) Seal(ctx context.Context, b database.Block, privKey ed25519.PrivateKey) (database.Block, error) {
+	return database.SignBlock(b, privKey)
+}
+
+// VerifySeal accepts every signed block, the
+// producer is checked against the signers later
+func (e ProofOfAuthority) VerifySeal(b database.Block) error {
+	return nil
+}
+
+// VerifyHeader checks that an authorized signer produced
+// the block in its own slot after the slot of the parent
+func (e ProofOfAuthority) VerifyHeader(chain ChainReader, header database.BlockHeader) error {
+	if header.Difficulty != 1 {
+		return fmt.Errorf("block %d has difficulty %d, expected 1", header.Number, header.Difficulty)
+	}
+
+	signers := chain.Signers()
+	if !isSigner(signers, header.Producer) {
+		return fmt.Errorf("block %d was produced by %s which is not an authorized signer", header.Number, header.Producer)
+	}
+
+	if header.Time < chain.GenesisTime() {
+		return fmt.Errorf("block %d time %d is before the genesis", header.Number, header.Time)
+	}
+
+	slot := e.slot(chain, header.Time)
+	if chain.NextBlockNumber() > 0 && slot <= e.slot(chain, chain.LatestBlock().Header.Time) {
+		return fmt.Errorf("block %d is in slot %d, which is not after the slot of its parent", header.Number, slot)
+	}
+
+	if signer := slotSigner(signers, slot); signer != header.Producer {
+		return fmt.Errorf("block %d was signed out of turn by %s, slot %d belongs to %s", header.Number, header.Producer, slot, signer)
+	}
+
+	return nil
+}
+
+// Finalize returns the block reward scheduled in genesis
+func (e ProofOfAuthority) Finalize(chain ChainReader, header database.BlockHeader) uint {
+	return blockReward(chain, header)
+}
+
+// slot returns the number of the block interval the time falls in
+func (e ProofOfAuthority) slot(chain ChainReader, time uint64) uint64 {
+	if time < chain.GenesisTime() {
+		return 0
+	}
+	return (time - chain.GenesisTime()) / e.params.BlockInterval
+}
+
+// slotSigner returns the signer whose turn it is in the slot,
+// the signers are sorted in the order they take turns
+func slotSigner(signers []database.Account, slot uint64) database.Account {
+	return signers[slot%uint64(len(signers))]
+}
+
+// isSigner checks if the account is one of the signers
+func isSigner(signers []database.Account, account database.Account) bool {
+	for _, signer := range signers {
+		if signer == account {
+			return true
+		}
+	}
+	return false
+}
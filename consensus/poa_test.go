@@ -0,0 +1,66 @@
+package consensus
+
+import (
+	"blockchain-sample/database"
+	"sort"
+	"testing"
+)
+
+func TestProofOfAuthorityVerifyHeader(t *testing.T) {
+	signers := make([]database.Account, 3)
+	for i, name := range []string{"alice", "bob", "carol"} {
+		_, signers[i] = testKey(name)
+	}
+	sort.Slice(signers, func(i, j int) bool { return signers[i] < signers[j] })
+	_, outsider := testKey("outsider")
+
+	params := database.ConsensusParams{Engine: EngineProofOfAuthority, Signers: signers, BlockInterval: 10}
+	engine := ProofOfAuthority{params}
+
+	// the latest block is in slot 1 of the second signer
+	chain := testChain{params, []database.BlockHeader{
+		{Number: 0, Time: 0, Difficulty: 1, Producer: signers[0]},
+		{Number: 1, Time: 15, Difficulty: 1, Producer: signers[1]},
+	}}
+
+	tests := []struct {
+		name     string
+		time     uint64
+		producer database.Account
+		valid    bool
+	}{
+		{"signer in turn", 20, signers[2], true},
+		{"signer in turn late in the slot", 29, signers[2], true},
+		{"later turn of a signer", 30, signers[0], true},
+		{"signer out of turn", 20, signers[0], false},
+		{"signer of the previous slot", 25, signers[1], false},
+		{"same slot as the parent", 19, signers[1], false},
+		{"earlier slot than the parent", 5, signers[0], false},
+		{"outsider", 20, outsider, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := database.BlockHeader{Number: 2, Time: tt.time, Difficulty: 1, Producer: tt.producer}
+			if err := engine.VerifyHeader(chain, header); (err == nil) != tt.valid {
+				t.Errorf("got error %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+
+	header := database.BlockHeader{Number: 2, Time: 20, Difficulty: 2, Producer: signers[2]}
+	if err := engine.VerifyHeader(chain, header); err == nil {
+		t.Errorf("got no error for a block with difficulty 2")
+	}
+
+	// every signer prepares a header in its own next slot
+	for _, signer := range signers {
+		header := database.BlockHeader{Number: 2, Producer: signer}
+		if err := engine.PrepareHeader(chain, &header, 16); err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.VerifyHeader(chain, header); err != nil {
+			t.Errorf("prepared header of %s at %d: %s", signer, header.Time, err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package consensus
+
+import (
+	"blockchain-sample/database"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// maxTarget is 2^256, one more than the largest block hash
+var maxTarget = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// maxRetargetFactor limits how much the difficulty changes in one retarget
+const maxRetargetFactor = 4
+
+// ProofOfWork is an engine in which producers mine blocks by searching
+// for a nonce which makes the block hash meet the difficulty.
+type ProofOfWork struct {
+	params database.ConsensusParams
+}
+
+// NewProofOfWork returns the proof of work engine
+func NewProofOfWork(params database.ConsensusParams) (Engine, error) {
+	if params.Difficulty == 0 {
+		return nil, fmt.Errorf("consensus difficulty must be greater than 0")
+	}
+
+	if params.RetargetInterval < 2 {
+		return nil, fmt.Errorf("consensus retarget_interval must be at least 2")
+	}
+
+	if len(params.Signers) > 0 {
+		return nil, fmt.Errorf("consensus signers are only used with proof of authority")
+	}
+
+	return ProofOfWork{params}, nil
+}
+
+// Target returns the value a block hash must be lower than
+// to meet the difficulty, i.e. 2^256 / difficulty
+func Target(difficulty uint64) *big.Int {
+	if difficulty == 0 {
+		difficulty = 1
+	}
+	return new(big.Int).Div(maxTarget, new(big.Int).SetUint64(difficulty))
+}
+
+// MeetsTarget checks if the hash as a big endian number is lower than the target
+func MeetsTarget(hash database.Hash, target *big.Int) bool {
+	return new(big.Int).SetBytes(hash[:]).Cmp(target) < 0
+}
+
+// PrepareHeader dates the block now with the retargeted difficulty
+func (e ProofOfWork) PrepareHeader(chain ChainReader, header *database.BlockHeader, now uint64) error {
+	header.Time = nextBlockTime(chain, now)
+	header.Difficulty = e.nextDifficulty(chain)
+	return nil
+}
+
+// Seal mines the block and signs it. Mining stops when ctx is cancelled.
+func (e ProofOfWork) Seal(ctx context.Context, b database.Block, privKey ed25519.PrivateKey) (database.Block, error) {
+	b, err := Mine(ctx, b)
+	if err != nil {
+		return database.Block{}, err
+	}
+	return database.SignBlock(b, privKey)
+}
+
+// VerifySeal checks that the block hash meets the difficulty of the block
+func (e ProofOfWork) VerifySeal(b database.Block) error {
+	blockHash, err := b.Hash()
+	if err != nil {
+		return err
+	}
+
+	if !MeetsTarget(blockHash, Target(b.Header.Difficulty)) {
+		return fmt.Errorf("block %d hash %x does not meet difficulty %d", b.Header.Number, blockHash, b.Header.Difficulty)
+	}
+	return nil
+}
+
+// VerifyHeader checks that the block was mined with the retargeted difficulty
+func (e ProofOfWork) VerifyHeader(chain ChainReader, header database.BlockHeader) error {
+	if expected := e.nextDifficulty(chain); header.Difficulty != expected {
+		return fmt.Errorf("block %d has difficulty %d, expected %d", header.Number, header.Difficulty, expected)
+	}
+	return nil
+}
+
+// Finalize returns the block reward scheduled in genesis
+func (e ProofOfWork) Finalize(chain ChainReader, header database.BlockHeader) uint {
+	return blockReward(chain, header)
+}
+
+// Mine searches for a nonce which makes the hash of the pending block meet
+// the target of its difficulty. The search stops when ctx is cancelled.
+func Mine(ctx context.Context, pending database.Block) (database.Block, error) {
+	target := Target(pending.Header.Difficulty)
+	start := time.Now()
+
+	for attempt := uint64(0); ; attempt++ {
+		if attempt%1000 == 0 {
+			select {
+			case <-ctx.Done():
+				return database.Block{}, fmt.Errorf("mining block %d cancelled: %s", pending.Header.Number, ctx.Err())
+			default:
+			}
+		}
+
+		pending.Header.Nonce = attempt
+		hash, err := pending.Hash()
+		if err != nil {
+			return database.Block{}, err
+		}
+
+		if MeetsTarget(hash, target) {
+			fmt.Printf("Mined block %d with nonce %d in %s\n", pending.Header.Number, attempt, time.Since(start))
+			return pending, nil
+		}
+	}
+}
+
+// nextDifficulty returns the difficulty of the next block of the chain.
+// The difficulty only changes every retarget interval. It is then scaled by
+// how much faster or slower than the block interval the last interval of
+// blocks was mined, at most by maxRetargetFactor either way.
+func (e ProofOfWork) nextDifficulty(chain ChainReader) uint64 {
+	headers := chain.RecentHeaders(int(e.params.RetargetInterval))
+	if len(headers) == 0 {
+		return e.params.Difficulty
+	}
+
+	last := headers[len(headers)-1]
+	if chain.NextBlockNumber()%e.params.RetargetInterval != 0 || uint64(len(headers)) < e.params.RetargetInterval {
+		return last.Difficulty
+	}
+
+	first := headers[0]
+
+	// the timespan of the interval is clamped so
+	// the difficulty changes by at most maxRetargetFactor
+	expectedSpan := e.params.BlockInterval * (e.params.RetargetInterval - 1)
+	actualSpan := uint64(0)
+	if last.Time > first.Time {
+		actualSpan = last.Time - first.Time
+	}
+	if actualSpan < expectedSpan/maxRetargetFactor {
+		actualSpan = expectedSpan / maxRetargetFactor
+	}
+	if actualSpan > expectedSpan*maxRetargetFactor {
+		actualSpan = expectedSpan * maxRetargetFactor
+	}
+	if actualSpan == 0 {
+		actualSpan = 1
+	}
+
+	difficulty := new(big.Int).SetUint64(last.Difficulty)
+	difficulty.Mul(difficulty, new(big.Int).SetUint64(expectedSpan))
+	difficulty.Div(difficulty, new(big.Int).SetUint64(actualSpan))
+
+	if !difficulty.IsUint64() {
+		return ^uint64(0)
+	}
+	if difficulty.Uint64() == 0 {
+		return 1
+	}
+	return difficulty.Uint64()
+}
@@ -0,0 +1,150 @@
+package consensus
+
+import (
+	"blockchain-sample/database"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"testing"
+)
+
+// testChain is a chain of headers read by the engines
+type testChain struct {
+	params  database.ConsensusParams
+	headers []database.BlockHeader
+}
+
+func (c testChain) Consensus() database.ConsensusParams { return c.params }
+func (c testChain) GenesisTime() uint64                 { return 0 }
+func (c testChain) MedianTimePast() uint64              { return 0 }
+func (c testChain) Signers() []database.Account         { return c.params.Signers }
+func (c testChain) Supply() uint                        { return 0 }
+
+func (c testChain) LatestBlock() database.Block {
+	if len(c.headers) == 0 {
+		return database.Block{}
+	}
+	return database.Block{Header: c.headers[len(c.headers)-1]}
+}
+
+func (c testChain) NextBlockNumber() uint64 {
+	return uint64(len(c.headers))
+}
+
+func (c testChain) RecentHeaders(n int) []database.BlockHeader {
+	if n > len(c.headers) {
+		n = len(c.headers)
+	}
+	return c.headers[len(c.headers)-n:]
+}
+
+// newTestChain returns a chain of blocks with the difficulty at the given times
+func newTestChain(params database.ConsensusParams, difficulty uint64, times ...uint64) testChain {
+	headers := make([]database.BlockHeader, len(times))
+	for i, time := range times {
+		headers[i] = database.BlockHeader{Number: uint64(i), Time: time, Difficulty: difficulty}
+	}
+	return testChain{params, headers}
+}
+
+func TestNextDifficulty(t *testing.T) {
+	params := database.ConsensusParams{
+		Engine:           EngineProofOfWork,
+		Difficulty:       1000,
+		BlockInterval:    10,
+		RetargetInterval: 5,
+	}
+	engine := ProofOfWork{params}
+
+	tests := []struct {
+		name       string
+		difficulty uint64
+		times      []uint64
+		want       uint64
+	}{
+		{"genesis difficulty", 0, nil, 1000},
+		{"between retargets", 500, []uint64{0, 1, 2}, 500},
+		{"after a retarget", 500, []uint64{0, 1, 2, 3, 4, 5}, 500},
+		{"on schedule", 1000, []uint64{0, 10, 20, 30, 40}, 1000},
+		{"twice as fast", 1000, []uint64{0, 5, 10, 15, 20}, 2000},
+		{"twice as slow", 1000, []uint64{0, 20, 40, 60, 80}, 500},
+		{"faster than the bound", 1000, []uint64{0, 0, 0, 0, 0}, 4000},
+		{"slower than the bound", 1000, []uint64{0, 100, 200, 300, 1000}, 250},
+		{"times out of order", 1000, []uint64{50, 40, 30, 20, 10}, 4000},
+		{"overflow", ^uint64(0) / 2, []uint64{0, 0, 0, 0, 0}, ^uint64(0)},
+		{"minimum", 1, []uint64{0, 100, 200, 300, 1000}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := newTestChain(params, tt.difficulty, tt.times...)
+			if got := engine.nextDifficulty(chain); got != tt.want {
+				t.Errorf("got difficulty %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// testKey returns the key and account of a test producer
+func testKey(name string) (ed25519.PrivateKey, database.Account) {
+	seed := sha256.Sum256([]byte(name))
+	privKey := ed25519.NewKeyFromSeed(seed[:])
+	return privKey, database.NewAccountFromPubKey(privKey.Public().(ed25519.PublicKey))
+}
+
+func TestProofOfWorkSeal(t *testing.T) {
+	engine := ProofOfWork{database.ConsensusParams{Engine: EngineProofOfWork, Difficulty: 256, RetargetInterval: 2}}
+	privKey, producer := testKey("producer")
+
+	pending, err := database.NewBlock("test", database.Hash{}, 0, 1, producer, 256, 0, database.Hash{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := engine.Seal(context.Background(), pending, privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tooEasy is the sealed block with the next nonce
+	// whose hash does not meet the difficulty
+	tooEasy := sealed
+	for {
+		tooEasy.Header.Nonce++
+		hash, err := tooEasy.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !MeetsTarget(hash, Target(tooEasy.Header.Difficulty)) {
+			break
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(b *database.Block)
+		valid  bool
+	}{
+		{"sealed", func(b *database.Block) {}, true},
+		{"too easy nonce", func(b *database.Block) { *b = tooEasy }, false},
+		{"harder difficulty claimed", func(b *database.Block) { b.Header.Difficulty = ^uint64(0) }, false},
+		{"header changed after sealing", func(b *database.Block) { b.Header.Time++ }, false},
+		{"not sealed", func(b *database.Block) { *b = pending; b.Header.Difficulty = ^uint64(0) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := sealed
+			tt.change(&b)
+			if err := engine.VerifySeal(b); (err == nil) != tt.valid {
+				t.Errorf("got error %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pending.Header.Difficulty = ^uint64(0)
+	if _, err := engine.Seal(ctx, pending, privKey); err == nil {
+		t.Errorf("got a sealed block after the context was cancelled")
+	}
+}
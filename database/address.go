@@ -0,0 +1,159 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Accounts are bech32 encoded addresses of the 20 byte hash
+// of their public key, e.g. paisa1gyt05enwsttn6tvzmwqeylt7csxfs23vqh7td3.
+// The checksum catches typos before funds are sent to a wrong account.
+const addressHrp = "paisa"
+
+const addressHashLen = 20
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+const bech32MaxLen = 90
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+// newAccountFromHash encodes the hash as an address
+func newAccountFromHash(hash []byte) Account {
+	data, _ := convertBits(hash[:addressHashLen], 8, 5, true)
+	return Account(bech32Encode(addressHrp, data))
+}
+
+// Validate checks that the account is a well formed address with a valid checksum
+func (a Account) Validate() error {
+	hrp, data, err := bech32Decode(string(a))
+	if err != nil {
+		return fmt.Errorf("invalid account %q: %s", a, err)
+	}
+
+	if hrp != addressHrp {
+		return fmt.Errorf("invalid account %q: prefix must be %q", a, addressHrp)
+	}
+
+	hash, err := convertBits(data, 5, 8, false)
+	if err != nil || len(hash) != addressHashLen {
+		return fmt.Errorf("invalid account %q: must encode %d bytes", a, addressHashLen)
+	}
+
+	return nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for _, c := range hrp {
+		expanded = append(expanded, byte(c>>5))
+	}
+	expanded = append(expanded, 0)
+	for _, c := range hrp {
+		expanded = append(expanded, byte(c&31))
+	}
+	return expanded
+}
+
+func bech32Checksum(hrp string, data []byte) []byte {
+	values := append(bech32HrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(values) ^ 1
+
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte((polymod >> uint(5*(5-i))) & 31)
+	}
+	return checksum
+}
+
+func bech32Encode(hrp string, data []byte) string {
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range append(data, bech32Checksum(hrp, data)...) {
+		sb.WriteByte(bech32Charset[d])
+	}
+	return sb.String()
+}
+
+// bech32Decode returns the human readable part and the 5 bit data of a
+// bech32 string after verifying its checksum. Only lower case is accepted
+// so each account has a single representation.
+func bech32Decode(s string) (string, []byte, error) {
+	if len(s) > bech32MaxLen {
+		return "", nil, fmt.Errorf("longer than %d characters", bech32MaxLen)
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < 33 || s[i] > 126 {
+			return "", nil, fmt.Errorf("invalid character %q", s[i])
+		}
+	}
+
+	if strings.ToLower(s) != s {
+		return "", nil, fmt.Errorf("must be lower case")
+	}
+
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, fmt.Errorf("missing separator or checksum")
+	}
+
+	hrp := s[:sep]
+	data := make([]byte, 0, len(s)-sep-1)
+	for _, c := range s[sep+1:] {
+		d := strings.IndexRune(bech32Charset, c)
+		if d < 0 {
+			return "", nil, fmt.Errorf("invalid character %q", c)
+		}
+		data = append(data, byte(d))
+	}
+
+	if bech32Polymod(append(bech32HrpExpand(hrp), data...)) != 1 {
+		return "", nil, fmt.Errorf("invalid checksum")
+	}
+
+	return hrp, data[:len(data)-6], nil
+}
+
+// convertBits regroups the bits of data from groups of fromBits to groups of toBits
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc := uint32(0)
+	bits := uint(0)
+	maxv := uint32(1)<<toBits - 1
+	converted := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+
+	for _, v := range data {
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			converted = append(converted, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			converted = append(converted, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+
+	return converted, nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestBech32Decode(t *testing.T) {
+	// bip-0173 test vectors, upper case strings are
+	// rejected as accounts only have a lower case form
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"a12uel5l", true},
+		{"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs", true},
+		{"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", true},
+		{"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j", true},
+		{"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", true},
+		{"?1ezyfcl", true},
+		{"A12UEL5L", false},
+		{"\x201nwldj5", false},
+		{"\x7f1axkwrx", false},
+		{"\x801eym55h", false},
+		{"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx", false},
+		{"pzry9x0s0muk", false},
+		{"1pzry9x0s0muk", false},
+		{"x1b4n0q5v", false},
+		{"li1dgmt3", false},
+		{"de1lg7wt\xff", false},
+		{"A1G7SGD8", false},
+		{"10a06t8", false},
+		{"1qzzfhee", false},
+	}
+
+	for _, tt := range tests {
+		hrp, data, err := bech32Decode(tt.value)
+		if (err == nil) != tt.valid {
+			t.Errorf("%q: got error %v, want valid %t", tt.value, err, tt.valid)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if encoded := bech32Encode(hrp, data); encoded != tt.value {
+			t.Errorf("%q: encodes back to %q", tt.value, encoded)
+		}
+	}
+}
+
+func TestAccountValidate(t *testing.T) {
+	hash := sha256.Sum256([]byte("account"))
+	account := newAccountFromHash(hash[:])
+
+	data, _ := convertBits(hash[:addressHashLen+1], 8, 5, true)
+	long := Account(bech32Encode(addressHrp, data))
+
+	data, _ = convertBits(hash[:addressHashLen], 8, 5, true)
+	otherHrp := Account(bech32Encode("coin", data))
+
+	typo := []byte(account)
+	if typo[10] == 'q' {
+		typo[10] = 'p'
+	} else {
+		typo[10] = 'q'
+	}
+
+	tests := []struct {
+		name    string
+		account Account
+		valid   bool
+	}{
+		{"address", account, true},
+		{"typo", Account(typo), false},
+		{"upper case", Account(bytes.ToUpper([]byte(account))), false},
+		{"other prefix", otherHrp, false},
+		{"longer hash", long, false},
+		{"legacy name", "dibek", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.account.Validate(); (err == nil) != tt.valid {
+				t.Errorf("%q: got error %v, want valid %t", tt.account, err, tt.valid)
+			}
+		})
+	}
+}
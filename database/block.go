@@ -1,13 +1,12 @@
 package database
 
 import (
-	"bufio"
 	"bytes"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"os"
-	"reflect"
+	"fmt"
 )
 
 type Hash [32]byte
@@ -17,21 +16,38 @@ func (h Hash) MarshalText() ([]byte, error) {
 }
 
 func (h *Hash) UnmarshalText(data []byte) error {
+	if len(data) != hex.EncodedLen(len(h)) {
+		return fmt.Errorf("hash must be %d hex characters, not %d", hex.EncodedLen(len(h)), len(data))
+	}
 	_, err := hex.Decode(h[:], data)
 	return err
 }
 
 // Block header stores parent block metadata
 // []Txn stores tsns in the new block
+// Sig is the signature of the producer over the block hash
 type Block struct {
 	Header BlockHeader `json:"header"`
-	Txns   []Txn       `json:"payload"`
+	Txns   []SignedTxn `json:"payload"`
+	Sig    *Signature  `json:"signature,omitempty"`
 }
 
+// BlockHeader stores the block metadata and
+// the account of the node which produced the block.
+// Nonce is changed while mining until the block hash
+// meets the target of the block's difficulty.
+// TxnRoot is the merkle root of the txns of the block
+// and StateRoot the root of the accounts after the block.
 type BlockHeader struct {
-	Parent Hash   `json:"parent"`
-	Number uint64 `json:"number"`
-	Time   uint64 `json:"time"`
+	ChainID    string  `json:"chain_id"`
+	Parent     Hash    `json:"parent"`
+	Number     uint64  `json:"number"`
+	Time       uint64  `json:"time"`
+	Producer   Account `json:"producer"`
+	Difficulty uint64  `json:"difficulty"`
+	Nonce      uint64  `json:"nonce"`
+	TxnRoot    Hash    `json:"txn_root"`
+	StateRoot  Hash    `json:"state_root"`
 }
 
 type BlockFs struct {
@@ -39,63 +55,72 @@ type BlockFs struct {
 	Value Block `json:"block"`
 }
 
-// NewBlock returns a Block including the given parameters
-func NewBlock(parent Hash, number, time uint64, txns []Txn) Block {
-	return Block{BlockHeader{parent, number, time}, txns}
+// NewBlock returns an unsigned Block including the given parameters
+func NewBlock(chainID string, parent Hash, number, time uint64, producer Account, difficulty, nonce uint64, stateRoot Hash, txns []SignedTxn) (Block, error) {
+	txnRoot, err := TxnRoot(txns)
+	if err != nil {
+		return Block{}, err
+	}
+	return Block{BlockHeader{chainID, parent, number, time, producer, difficulty, nonce, txnRoot, stateRoot}, txns, nil}, nil
 }
 
-// Hash returns the sha2356 hash of given blcok
+// Hash returns the sha256 hash of the block header.
+// The header commits to the txns with the txn root
+// and the signature is not part of the hash.
 func (b Block) Hash() (Hash, error) {
-	blockJson, err := json.Marshal(b)
+	return b.Header.Hash()
+}
+
+// Hash returns the sha256 hash of the encoded header
+func (h BlockHeader) Hash() (Hash, error) {
+	headerJson, err := json.Marshal(h)
 	if err != nil {
-		return Hash{}, nil
+		return Hash{}, err
 	}
-	return sha256.Sum256(blockJson), nil
+	return sha256.Sum256(headerJson), nil
 }
 
-func GetBlocksAfter(blockHash Hash, dataDir string) ([]Block, error) {
-	// open file and load blockchain
-	f, err := os.OpenFile(getBlocksDbFilePath(dataDir), os.O_RDONLY, 0600)
+// SignBlock signs the block hash with the private key of the block producer
+func SignBlock(b Block, privKey ed25519.PrivateKey) (Block, error) {
+	pubKey := privKey.Public().(ed25519.PublicKey)
+	if NewAccountFromPubKey(pubKey) != b.Header.Producer {
+		return Block{}, fmt.Errorf("key does not belong to block producer %s", b.Header.Producer)
+	}
+
+	hash, err := b.Hash()
 	if err != nil {
-		return nil, err
+		return Block{}, err
 	}
 
-	// create blocks to add newer blocks if present
-	blocks := make([]Block, 0)
-	newBlock := false
+	b.Sig = &Signature{Bytes(pubKey), sign(hash[:], privKey)}
+	return b, nil
+}
 
-	if reflect.DeepEqual(blockHash, Hash{}) {
-		newBlock = true
+// IsAuthentic checks that the block was signed by its producer
+// and that the block was not modified after it was signed
+func (b Block) IsAuthentic() error {
+	if b.Sig == nil {
+		return fmt.Errorf("block %d is not signed", b.Header.Number)
+	}
+
+	if err := b.Header.Producer.Validate(); err != nil {
+		return fmt.Errorf("invalid block producer: %s", err)
+	}
+
+	if NewAccountFromPubKey(ed25519.PublicKey(b.Sig.PubKey)) != b.Header.Producer {
+		return fmt.Errorf("block %d was not signed by its producer %s", b.Header.Number, b.Header.Producer)
+	}
+
+	hash, err := b.Hash()
+	if err != nil {
+		return err
 	}
 
-	// loop over blockchain
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return blocks, err
-		}
-
-		// unmarshal each block into blockFs
-		var blockFs BlockFs
-		err = json.Unmarshal(scanner.Bytes(), &blockFs)
-		if err != nil {
-			return nil, err
-		}
-
-		// if newBlock, add to blocks
-		if newBlock {
-			blocks = append(blocks, blockFs.Value)
-			continue
-		}
-
-		// if blockHash matches the blockFs.Key, the node is at current block
-		// the following blocks are newer blocks
-		if blockHash == blockFs.Key {
-			newBlock = true
-		}
+	if !verify(hash[:], b.Sig.PubKey, b.Sig.Sig) {
+		return fmt.Errorf("invalid signature on block %d", b.Header.Number)
 	}
 
-	return blocks, nil
+	return nil
 }
 
 func (h Hash) IsEmpty() bool {
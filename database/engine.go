@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"sort"
+)
+
+// Engine implements the consensus rules of a chain which differ between
+// deployments: how blocks are produced, sealed and verified and how their
+// producers are rewarded. The engines of the consensus package register
+// themselves and the genesis picks one by its name.
+type Engine interface {
+	// PrepareHeader sets the time and difficulty of the header of a new block
+	// on top of the chain. The time may be later than now if the producer
+	// has to wait before the block can be sealed.
+	PrepareHeader(chain ChainReader, header *BlockHeader, now uint64) error
+	// Seal returns the block ready to be added to the chain,
+	// signed with the private key of its producer
+	Seal(ctx context.Context, b Block, privKey ed25519.PrivateKey) (Block, error)
+	// VerifySeal checks the consensus fields of a block which do not depend
+	// on the chain, so that invalid side branches are not even stored
+	VerifySeal(b Block) error
+	// VerifyHeader checks the header of a block on top of the chain
+	VerifyHeader(chain ChainReader, header BlockHeader) error
+	// Finalize returns the reward the coinbase of the block pays its producer
+	Finalize(chain ChainReader, header BlockHeader) uint
+}
+
+// ChainReader gives an engine access to the chain a block is built on
+type ChainReader interface {
+	Consensus() ConsensusParams
+	GenesisTime() uint64
+	LatestBlock() Block
+	NextBlockNumber() uint64
+	MedianTimePast() uint64
+	RecentHeaders(n int) []BlockHeader
+	Signers() []Account
+	Supply() uint
+}
+
+// NewEngineFunc creates an engine for the consensus params
+// after checking the params the engine depends on
+type NewEngineFunc func(params ConsensusParams) (Engine, error)
+
+var engines = make(map[string]NewEngineFunc)
+
+// RegisterEngine makes an engine available to genesis under the name
+func RegisterEngine(name string, newEngine NewEngineFunc) {
+	if _, ok := engines[name]; ok {
+		panic(fmt.Sprintf("consensus engine %q is registered twice", name))
+	}
+	engines[name] = newEngine
+}
+
+// newEngine creates the engine named by the consensus params
+func newEngine(params ConsensusParams) (Engine, error) {
+	if len(engines) == 0 {
+		return nil, fmt.Errorf("no consensus engines are registered, the blockchain-sample/consensus package must be imported")
+	}
+
+	newEngine, ok := engines[params.Engine]
+	if !ok {
+		names := make([]string, 0, len(engines))
+		for name := range engines {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown consensus engine %q, known engines are %q", params.Engine, names)
+	}
+	return newEngine(params)
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Checkpoint is a block the operator of a node trusts to be
+// part of the canonical chain at the given number
+type Checkpoint struct {
+	Number uint64 `json:"number"`
+	Hash   Hash   `json:"hash"`
+}
+
+// Finality bounds how much of the chain a node lets peers rewrite.
+// Chains contradicting a checkpoint are refused and so are reorgs
+// rolling back more than MaxReorgDepth blocks. A MaxReorgDepth of
+// 0 does not limit the depth of reorgs.
+type Finality struct {
+	Checkpoints   []Checkpoint
+	MaxReorgDepth uint64
+}
+
+// ParseCheckpoint parses a checkpoint in the format number:hash
+func ParseCheckpoint(value string) (Checkpoint, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return Checkpoint{}, fmt.Errorf("checkpoint %q must be in the format number:hash", value)
+	}
+
+	number, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint number %q: %s", parts[0], err)
+	}
+
+	checkpoint := Checkpoint{Number: number}
+	if err := checkpoint.Hash.UnmarshalText([]byte(parts[1])); err != nil {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint hash %q: %s", parts[1], err)
+	}
+
+	return checkpoint, nil
+}
+
+// SetFinality sets the checkpoints and the maximum reorg depth of the node.
+// It fails if the canonical chain already contradicts a checkpoint.
+func (s *State) SetFinality(finality Finality) error {
+	checkpoints := make(map[uint64]Hash)
+	for _, checkpoint := range finality.Checkpoints {
+		if hash, ok := checkpoints[checkpoint.Number]; ok && hash != checkpoint.Hash {
+			return fmt.Errorf("two checkpoints for block %d", checkpoint.Number)
+		}
+		checkpoints[checkpoint.Number] = checkpoint.Hash
+
+		if checkpoint.Number < uint64(len(s.canonical)) && s.canonical[checkpoint.Number] != checkpoint.Hash {
+			return fmt.Errorf("block %d of the local chain is %x, which contradicts checkpoint %x", checkpoint.Number, s.canonical[checkpoint.Number], checkpoint.Hash)
+		}
+	}
+
+	s.checkpoints = checkpoints
+	s.maxReorgDepth = finality.MaxReorgDepth
+	return nil
+}
+
+// checkCheckpoint refuses a block whose number is checkpointed with another hash
+func (s *State) checkCheckpoint(b Block, hash Hash) error {
+	if checkpoint, ok := s.checkpoints[b.Header.Number]; ok && checkpoint != hash {
+		return fmt.Errorf("block %d %x contradicts checkpoint %x", b.Header.Number, hash, checkpoint)
+	}
+	return nil
+}
+
+// checkReorgDepth refuses a branch forking from the canonical chain
+// before a checkpoint or more than the maximum reorg depth blocks deep.
+// keep is the number of canonical blocks up to the fork point.
+func (s *State) checkReorgDepth(keep int) error {
+	for number := range s.checkpoints {
+		if number >= uint64(keep) && number < uint64(len(s.canonical)) {
+			return fmt.Errorf("branch would roll back checkpointed block %d", number)
+		}
+	}
+
+	depth := uint64(len(s.canonical) - keep)
+	if s.maxReorgDepth > 0 && depth > s.maxReorgDepth {
+		return fmt.Errorf("branch would roll back %d blocks, the maximum reorg depth is %d", depth, s.maxReorgDepth)
+	}
+	return nil
+}
@@ -0,0 +1,509 @@
+package database
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// blockNode stores a known block of the canonical chain or of a side branch
+type blockNode struct {
+	block Block
+	// work is the cumulative work from the first block up to this block
+	work *big.Int
+	// undo rolls the block back while it is part of the canonical chain
+	undo blockUndo
+	// signers and votes after the block, known without applying
+	// the block so that side branches can be verified on arrival
+	signers []Account
+	votes   []signerVote
+}
+
+// branchChain is the chain up to the parent of a block in the block tree
+// as read by the engine to verify the header of the block. The supply is
+// the one of the canonical chain, it is not needed to verify a header.
+type branchChain struct {
+	*State
+	parent Hash
+}
+
+func (c branchChain) LatestBlock() Block {
+	if node, ok := c.blocks[c.parent]; ok {
+		return node.block
+	}
+	return Block{}
+}
+
+func (c branchChain) NextBlockNumber() uint64 {
+	if node, ok := c.blocks[c.parent]; ok {
+		return node.block.Header.Number + 1
+	}
+	return 0
+}
+
+func (c branchChain) MedianTimePast() uint64 {
+	return c.medianTimePast(c.parent)
+}
+
+// RecentHeaders returns the headers of at most the n latest blocks of the
+// branch, oldest first. n comes from genesis and may be far larger than
+// the branch, so the headers are only allocated for the blocks there are.
+func (c branchChain) RecentHeaders(n int) []BlockHeader {
+	parent, ok := c.blocks[c.parent]
+	if !ok || n <= 0 {
+		return []BlockHeader{}
+	}
+	if height := parent.block.Header.Number + 1; uint64(n) > height {
+		n = int(height)
+	}
+
+	headers := make([]BlockHeader, n)
+	for i, hash := n-1, c.parent; i >= 0; i-- {
+		node, ok := c.blocks[hash]
+		if !ok {
+			return headers[i+1:]
+		}
+		headers[i] = node.block.Header
+		hash = node.block.Header.Parent
+	}
+	return headers
+}
+
+func (c branchChain) Signers() []Account {
+	signers, _ := c.signersAfter(c.parent)
+	return append([]Account{}, signers...)
+}
+
+// signersAfter returns the signers and votes after the block with the given hash
+func (s *State) signersAfter(hash Hash) ([]Account, []signerVote) {
+	if node, ok := s.blocks[hash]; ok {
+		return node.signers, node.votes
+	}
+	return sortSigners(s.consensus.Signers), []signerVote{}
+}
+
+// branchSigners applies the votes of the block to the signers and votes
+// after its parent. Votes only depend on the signers and earlier votes,
+// so the rest of the state is not needed.
+func (s *State) branchSigners(b Block) ([]Account, []signerVote, error) {
+	signers, votes := s.signersAfter(b.Header.Parent)
+	voting := &State{signers: signers, votes: votes}
+	for _, txn := range b.Txns {
+		if !txn.IsVote() {
+			continue
+		}
+		if err := applyVote(txn, voting); err != nil {
+			return nil, nil, err
+		}
+	}
+	return voting.signers, voting.votes, nil
+}
+
+// blockUndo stores what the state was before a block was applied
+type blockUndo struct {
+	accounts map[Account]accountUndo
+	supply   uint
+	signers  []Account
+	votes    []signerVote
+}
+
+// accountUndo stores the balance, nonce and fees of an account
+// and whether the account was present in the state at all
+type accountUndo struct {
+	balance    uint
+	hasBalance bool
+	nonce      uint64
+	hasNonce   bool
+	fees       uint
+	hasFees    bool
+}
+
+// newBlockUndo records every account the block can change
+// before the block is applied to the state
+func newBlockUndo(b Block, s *State) blockUndo {
+	undo := blockUndo{make(map[Account]accountUndo), s.supply, s.signers, s.votes}
+
+	accounts := []Account{b.Header.Producer}
+	for _, txn := range b.Txns {
+		accounts = append(accounts, txn.From, txn.To)
+	}
+
+	for _, account := range accounts {
+		if account == "" {
+			continue
+		}
+
+		a := accountUndo{}
+		a.balance, a.hasBalance = s.Balances[account]
+		a.nonce, a.hasNonce = s.Nonces[account]
+		a.fees, a.hasFees = s.Fees[account]
+		undo.accounts[account] = a
+	}
+	return undo
+}
+
+// revert restores the state from before the block was applied
+func (u blockUndo) revert(s *State) {
+	for account, a := range u.accounts {
+		delete(s.Balances, account)
+		delete(s.Nonces, account)
+		delete(s.Fees, account)
+
+		if a.hasBalance {
+			s.Balances[account] = a.balance
+		}
+		if a.hasNonce {
+			s.Nonces[account] = a.nonce
+		}
+		if a.hasFees {
+			s.Fees[account] = a.fees
+		}
+	}
+	s.supply = u.supply
+	s.signers = u.signers
+	s.votes = u.votes
+}
+
+// blockWork returns the expected number of hashes needed to mine the block
+func blockWork(b Block) *big.Int {
+	return new(big.Int).SetUint64(b.Header.Difficulty)
+}
+
+// TotalWork returns the cumulative work of the canonical chain
+func (s *State) TotalWork() *big.Int {
+	if !s.hasGenesisBlock {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(s.blocks[s.latestBlockHash].work)
+}
+
+// addBlock adds a block to the block tree. A block extending a branch
+// with more work than the canonical chain makes that branch canonical.
+// Blocks are written to the db file once they are known to be valid
+// when persist is set. Already known blocks are ignored.
+func (s *State) addBlock(b Block, persist bool) (Hash, error) {
+	hash, err := b.Hash()
+	if err != nil {
+		return Hash{}, err
+	}
+
+	if _, ok := s.blocks[hash]; ok {
+		return hash, nil
+	}
+
+	parentWork, err := s.checkBlockHeader(b)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	// refuse branches rewriting finalized blocks
+	if err := s.checkCheckpoint(b, hash); err != nil {
+		return Hash{}, err
+	}
+	_, keep := s.branchOf(b.Header.Parent)
+	if err := s.checkReorgDepth(keep); err != nil {
+		return Hash{}, err
+	}
+
+	signers, votes, err := s.branchSigners(b)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	node := &blockNode{block: b, work: new(big.Int).Add(parentWork, blockWork(b)), signers: signers, votes: votes}
+
+	// a side branch with less work is kept until it gets more work
+	if node.work.Cmp(s.TotalWork()) <= 0 {
+		if persist {
+			if err := s.writeBlock(hash, b); err != nil {
+				return Hash{}, err
+			}
+		}
+		s.insertBlock(hash, node)
+		return hash, nil
+	}
+
+	s.insertBlock(hash, node)
+	pending, disconnected, connected, err := s.switchTo(hash)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	if persist {
+		if err := s.writeBlock(hash, b); err != nil {
+			s.dropBranch(hash)
+			return Hash{}, err
+		}
+	}
+
+	if len(disconnected) > 0 {
+		fmt.Printf("Reorganized the chain to block %x: %d blocks orphaned, %d blocks added\n", hash, len(disconnected), len(connected))
+	}
+
+	return hash, s.commit(pending, disconnected, connected)
+}
+
+// checkBlockHeader validates what can be checked without the
+// balances at the parent of the block and returns the parent's work
+func (s *State) checkBlockHeader(b Block) (*big.Int, error) {
+	// validate that the block belongs to this chain
+	if b.Header.ChainID != s.chainID {
+		return nil, fmt.Errorf("block belongs to chain %q, not %q", b.Header.ChainID, s.chainID)
+	}
+
+	// the first block must be built on top of this genesis
+	if b.Header.Number == 0 && b.Header.Parent != s.genesisHash {
+		return nil, fmt.Errorf("blockchain was not built on genesis %x, found parent %x", s.genesisHash, b.Header.Parent)
+	}
+
+	parentWork := new(big.Int)
+	if b.Header.Number > 0 {
+		parent, ok := s.blocks[b.Header.Parent]
+		if !ok {
+			return nil, fmt.Errorf("block %d has unknown parent %x", b.Header.Number, b.Header.Parent)
+		}
+
+		if b.Header.Number != parent.block.Header.Number+1 {
+			return nil, fmt.Errorf("block %d does not follow its parent block %d", b.Header.Number, parent.block.Header.Number)
+		}
+		parentWork = parent.work
+	}
+
+	if err := s.checkBlockTime(b); err != nil {
+		return nil, err
+	}
+
+	// validate the block size, side blocks are stored too
+	if uint(len(b.Txns)) > s.consensus.MaxBlockTxns {
+		return nil, fmt.Errorf("block has %d txns, the maximum is %d", len(b.Txns), s.consensus.MaxBlockTxns)
+	}
+
+	// blocks are known by the hash of their header, a block whose txns
+	// do not match its header must not be stored in place of the real one
+	if err := checkTxnRoot(b); err != nil {
+		return nil, err
+	}
+
+	if err := s.engine.VerifySeal(b); err != nil {
+		return nil, err
+	}
+
+	// the difficulty and producer are checked against the branch of the
+	// parent, so that cheap blocks are not stored even as a side branch
+	if err := s.engine.VerifyHeader(branchChain{s, b.Header.Parent}, b.Header); err != nil {
+		return nil, err
+	}
+
+	// validate that the block was signed by its producer
+	if err := b.IsAuthentic(); err != nil {
+		return nil, err
+	}
+
+	return parentWork, nil
+}
+
+// switchTo returns the state with the block of the given hash as the latest
+// block. The canonical blocks after the fork point are rolled back and
+// the blocks of the branch are applied in order. The state is unchanged,
+// a block of the branch which is invalid is dropped with its descendants.
+func (s *State) switchTo(tip Hash) (State, []Hash, []Hash, error) {
+	branch, keep := s.branchOf(tip)
+	if err := s.checkReorgDepth(keep); err != nil {
+		return State{}, nil, nil, err
+	}
+
+	pending := s.copy()
+	disconnected := make([]Hash, 0)
+	for i := len(s.canonical) - 1; i >= keep; i-- {
+		s.blocks[s.canonical[i]].undo.revert(&pending)
+		disconnected = append(disconnected, s.canonical[i])
+	}
+	// the slice is capped so that the branch does not
+	// overwrite the blocks of the current canonical chain
+	pending.setCanonical(s.canonical[:keep:keep])
+
+	for _, hash := range branch {
+		node := s.blocks[hash]
+		undo := newBlockUndo(node.block, &pending)
+
+		if err := applyBlock(node.block, &pending); err != nil {
+			s.dropBranch(hash)
+			return State{}, nil, nil, err
+		}
+
+		node.undo = undo
+		pending.setCanonical(append(pending.canonical, hash))
+	}
+
+	return pending, disconnected, branch, nil
+}
+
+// branchOf returns the blocks up to the given hash which are not part
+// of the canonical chain, oldest first, and the number of canonical
+// blocks up to the fork point of the branch
+func (s *State) branchOf(hash Hash) ([]Hash, int) {
+	branch := make([]Hash, 0)
+	fork := hash
+	for fork != s.genesisHash && !s.isCanonical(fork) {
+		branch = append([]Hash{fork}, branch...)
+		fork = s.blocks[fork].block.Header.Parent
+	}
+
+	if fork == s.genesisHash {
+		return branch, 0
+	}
+	return branch, int(s.blocks[fork].block.Header.Number) + 1
+}
+
+// commit makes the state after a switch to another branch the current state.
+// The txn index is updated and the txns of orphaned blocks which
+// are not part of the new branch go back into the mempool.
+func (s *State) commit(pending State, disconnected []Hash, connected []Hash) error {
+	s.Balances = pending.Balances
+	s.Nonces = pending.Nonces
+	s.Fees = pending.Fees
+	s.supply = pending.supply
+	s.signers = pending.signers
+	s.votes = pending.votes
+	s.setCanonical(pending.canonical)
+
+	orphaned := make([]pendingTxn, 0)
+	now := time.Now()
+	for i := len(disconnected) - 1; i >= 0; i-- {
+		b := s.blocks[disconnected[i]].block
+		if err := unindexTxns(s.txnIndex, b, disconnected[i]); err != nil {
+			return err
+		}
+
+		for index, txn := range b.Txns {
+			if txn.IsReward() {
+				continue
+			}
+
+			p, err := newPendingTxn(txn, now)
+			if err != nil {
+				return err
+			}
+			orphaned = append(orphaned, p)
+			s.txnFates.orphan(p.hash, TxnLocation{disconnected[i], b.Header.Number, index})
+		}
+	}
+
+	for _, hash := range connected {
+		b := s.blocks[hash].block
+		if err := indexTxns(s.txnIndex, b, hash); err != nil {
+			return err
+		}
+
+		for _, txn := range b.Txns {
+			txnHash, err := txn.Hash()
+			if err != nil {
+				return err
+			}
+			s.txnFates.forget(txnHash, true)
+		}
+	}
+
+	return s.refillMempool(append(orphaned, s.txnMempool.txns...))
+}
+
+// setCanonical sets the canonical chain and its latest block
+func (s *State) setCanonical(canonical []Hash) {
+	s.canonical = canonical
+
+	if len(canonical) == 0 {
+		s.latestBlock = Block{}
+		s.latestBlockHash = s.genesisHash
+		s.hasGenesisBlock = false
+		return
+	}
+
+	s.latestBlockHash = canonical[len(canonical)-1]
+	s.latestBlock = s.blocks[s.latestBlockHash].block
+	s.hasGenesisBlock = true
+}
+
+// isCanonical checks if the block with the given hash is part of the canonical chain
+func (s *State) isCanonical(hash Hash) bool {
+	node, ok := s.blocks[hash]
+	if !ok {
+		return false
+	}
+
+	number := node.block.Header.Number
+	return number < uint64(len(s.canonical)) && s.canonical[number] == hash
+}
+
+// insertBlock adds the block to the block tree and to the children of its parent
+func (s *State) insertBlock(hash Hash, node *blockNode) {
+	s.blocks[hash] = node
+	parent := node.block.Header.Parent
+	s.children[parent] = append(s.children[parent], hash)
+}
+
+// dropBranch forgets an invalid block and every block built on top of it
+func (s *State) dropBranch(hash Hash) {
+	for _, child := range s.children[hash] {
+		s.dropBranch(child)
+	}
+	delete(s.children, hash)
+
+	node, ok := s.blocks[hash]
+	if !ok {
+		return
+	}
+	delete(s.blocks, hash)
+
+	parent := node.block.Header.Parent
+	siblings := make([]Hash, 0, len(s.children[parent]))
+	for _, sibling := range s.children[parent] {
+		if sibling != hash {
+			siblings = append(siblings, sibling)
+		}
+	}
+	if len(siblings) == 0 {
+		delete(s.children, parent)
+		return
+	}
+	s.children[parent] = siblings
+}
+
+// RecentHeaders returns the headers of at most
+// the n latest canonical blocks, oldest first
+func (s *State) RecentHeaders(n int) []BlockHeader {
+	if n <= 0 {
+		return []BlockHeader{}
+	}
+
+	start := 0
+	if len(s.canonical) > n {
+		start = len(s.canonical) - n
+	}
+
+	headers := make([]BlockHeader, 0, len(s.canonical)-start)
+	for _, hash := range s.canonical[start:] {
+		headers = append(headers, s.blocks[hash].block.Header)
+	}
+	return headers
+}
+
+// BlocksAfter returns the canonical blocks following the block with the given hash.
+// The blocks after the fork point are returned for a block of a side branch
+// and every canonical block for the genesis hash or an unknown hash.
+func (s *State) BlocksAfter(hash Hash) []Block {
+	start := uint64(0)
+	if _, ok := s.blocks[hash]; ok {
+		for !s.isCanonical(hash) && hash != s.genesisHash {
+			hash = s.blocks[hash].block.Header.Parent
+		}
+		if hash != s.genesisHash {
+			start = s.blocks[hash].block.Header.Number + 1
+		}
+	}
+
+	blocks := make([]Block, 0)
+	for _, blockHash := range s.canonical[start:] {
+		blocks = append(blocks, s.blocks[blockHash].block)
+	}
+	return blocks
+}
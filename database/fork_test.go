@@ -0,0 +1,419 @@
+package database
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// testBranches are two branches forking after a common block.
+// Branch a has one block before it is extended by two more,
+// branch b has three blocks.
+type testBranches struct {
+	blocks map[string]Block
+	supply map[string]uint
+}
+
+func newTestBranches(t *testing.T) testBranches {
+	t.Helper()
+
+	branches := testBranches{make(map[string]Block), make(map[string]uint)}
+	add := func(name string, s *State, b Block) {
+		branches.blocks[name] = b
+		branches.supply[name] = s.Supply()
+	}
+
+	a, _ := newTestState(t, nil, "alice", "dave", "erin")
+	b, _ := newTestState(t, nil, "alice", "dave", "erin")
+
+	add("c0", a, produceTestBlock(t, a, "producer"))
+	if _, err := b.AddBlock(branches.blocks["c0"]); err != nil {
+		t.Fatal(err)
+	}
+
+	add("a1", a, produceTestBlock(t, a, "producer-a",
+		testTxn(t, "alice", "bob", 100, 1, 0),
+		testTxn(t, "erin", "bob", 10, 1, 0),
+	))
+	add("a2", a, produceTestBlock(t, a, "producer-a"))
+	add("a3", a, produceTestBlock(t, a, "producer-a"))
+
+	add("b1", b, produceTestBlock(t, b, "producer-b", testTxn(t, "alice", "carol", 50, 2, 0)))
+	add("b2", b, produceTestBlock(t, b, "producer-b", testTxn(t, "dave", "carol", 20, 1, 0)))
+	add("b3", b, produceTestBlock(t, b, "producer-b"))
+
+	return branches
+}
+
+func TestAddBlockReorg(t *testing.T) {
+	branches := newTestBranches(t)
+
+	tests := []struct {
+		name   string
+		blocks []string
+		tip    string
+	}{
+		{"single chain", []string{"c0", "a1", "a2", "a3"}, "a3"},
+		{"equal work keeps the first branch", []string{"c0", "a1", "b1"}, "a1"},
+		{"more work switches branch", []string{"c0", "a1", "b1", "b2"}, "b2"},
+		{"switch back", []string{"c0", "a1", "b1", "b2", "a2", "a3"}, "a3"},
+		{"side branch first", []string{"c0", "b1", "a1", "a2"}, "a2"},
+		{"known blocks are ignored", []string{"c0", "a1", "a1", "b1", "b2", "b1"}, "b2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dataDir := newTestState(t, nil, "alice", "dave", "erin")
+			for _, name := range tt.blocks {
+				if _, err := s.AddBlock(branches.blocks[name]); err != nil {
+					t.Fatalf("adding block %s: %s", name, err)
+				}
+			}
+
+			tip := branches.blocks[tt.tip]
+			check := func(s *State) {
+				t.Helper()
+
+				if hash := blockHash(t, tip); s.LatestBlockHash() != hash {
+					t.Errorf("latest block is %x, want %s %x", s.LatestBlockHash(), tt.tip, hash)
+				}
+				if root := s.StateRoot(); root != tip.Header.StateRoot {
+					t.Errorf("state root is %x, want %x of block %s", root, tip.Header.StateRoot, tt.tip)
+				}
+				if supply := s.Supply(); supply != branches.supply[tt.tip] {
+					t.Errorf("supply is %d, want %d", supply, branches.supply[tt.tip])
+				}
+				if work := s.TotalWork().Uint64(); work != tip.Header.Number+1 {
+					t.Errorf("total work is %d, want %d", work, tip.Header.Number+1)
+				}
+			}
+			check(s)
+
+			// the db file replays to the same chain after a restart
+			s.Close()
+			check(openTestState(t, dataDir))
+		})
+	}
+}
+
+func TestAddBlockFinality(t *testing.T) {
+	branches := newTestBranches(t)
+	checkpoint := func(name string) Checkpoint {
+		return Checkpoint{branches.blocks[name].Header.Number, blockHash(t, branches.blocks[name])}
+	}
+
+	tests := []struct {
+		name     string
+		finality Finality
+		blocks   []string
+		refused  []string
+		tip      string
+	}{
+		{"no finality", Finality{}, []string{"c0", "a1", "a2", "b1", "b2", "b3"}, nil, "b3"},
+		{"branch contradicting a checkpoint", Finality{Checkpoints: []Checkpoint{checkpoint("a1")}}, []string{"c0", "a1", "b1", "b2"}, []string{"b1", "b2"}, "a1"},
+		{"chain contradicting a checkpoint", Finality{Checkpoints: []Checkpoint{checkpoint("b1")}}, []string{"c0", "a1", "b1", "b2"}, []string{"a1"}, "b2"},
+		{"checkpoint before the fork", Finality{Checkpoints: []Checkpoint{checkpoint("c0")}}, []string{"c0", "a1", "b1", "b2"}, nil, "b2"},
+		{"reorg at the maximum depth", Finality{MaxReorgDepth: 2}, []string{"c0", "a1", "a2", "b1", "b2", "b3"}, nil, "b3"},
+		{"reorg deeper than the maximum depth", Finality{MaxReorgDepth: 1}, []string{"c0", "a1", "a2", "b1", "b2", "b3"}, []string{"b1", "b2", "b3"}, "a2"},
+		{"extending the chain is not a reorg", Finality{MaxReorgDepth: 1}, []string{"c0", "a1", "a2", "a3"}, nil, "a3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestState(t, nil, "alice", "dave", "erin")
+			if err := s.SetFinality(tt.finality); err != nil {
+				t.Fatal(err)
+			}
+
+			refused := make(map[string]bool)
+			for _, name := range tt.refused {
+				refused[name] = true
+			}
+			for _, name := range tt.blocks {
+				if _, err := s.AddBlock(branches.blocks[name]); (err != nil) != refused[name] {
+					t.Errorf("adding block %s: got error %v, want refused %t", name, err, refused[name])
+				}
+			}
+
+			if hash := blockHash(t, branches.blocks[tt.tip]); s.LatestBlockHash() != hash {
+				t.Errorf("latest block is %x, want %s %x", s.LatestBlockHash(), tt.tip, hash)
+			}
+		})
+	}
+
+	// a local chain contradicting a checkpoint is refused
+	s, _ := newTestState(t, nil, "alice", "dave", "erin")
+	for _, name := range []string{"c0", "a1"} {
+		if _, err := s.AddBlock(branches.blocks[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetFinality(Finality{Checkpoints: []Checkpoint{checkpoint("b1")}}); err == nil {
+		t.Errorf("got no error for a checkpoint contradicting the local chain")
+	}
+}
+
+func TestAddBlockOrphanedTxns(t *testing.T) {
+	branches := newTestBranches(t)
+	s, _ := newTestState(t, nil, "alice", "dave", "erin")
+	for _, name := range []string{"c0", "a1", "b1", "b2"} {
+		if _, err := s.AddBlock(branches.blocks[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		txn    SignedTxn
+		status string
+		block  string
+		final  bool
+	}{
+		// alice's txn conflicts with the nonce of her txn in b1
+		{"conflicting txn is dropped", branches.blocks["a1"].Txns[1], TxnOrphaned, "a1", true},
+		{"other txn is pending again", branches.blocks["a1"].Txns[2], TxnOrphaned, "a1", false},
+		{"txn of the new branch", branches.blocks["b1"].Txns[1], TxnIncluded, "b1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.txn.Hash()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			status := s.TxnStatus(hash)
+			if status.Status != tt.status {
+				t.Fatalf("got status %q, want %q", status.Status, tt.status)
+			}
+			if status.Final() != tt.final {
+				t.Errorf("got final %t, want %t", status.Final(), tt.final)
+			}
+			if status.Location == nil || status.Location.BlockHash != blockHash(t, branches.blocks[tt.block]) {
+				t.Errorf("got location %+v, want block %s", status.Location, tt.block)
+			}
+		})
+	}
+
+	if count := s.PendingCount(); count != 1 {
+		t.Errorf("got %d pending txns, want only erin's txn", count)
+	}
+}
+
+func TestAddBlockSideBranchChecks(t *testing.T) {
+	branches := newTestBranches(t)
+	producerKey, producer := testKey("producer-b")
+	c0 := blockHash(t, branches.blocks["c0"])
+
+	// sign returns the block signed after changing its header
+	sign := func(b Block, change func(*BlockHeader)) Block {
+		change(&b.Header)
+		b, err := SignBlock(b, producerKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	badRoot := sign(branches.blocks["b1"], func(h *BlockHeader) { h.StateRoot = Hash{1} })
+	onBadRoot, err := NewBlock("test", blockHash(t, badRoot), 2, testBlockTime(2), producer, 1, 0, Hash{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	onBadRoot = sign(onBadRoot, func(h *BlockHeader) {})
+
+	// oversized has more txns than a block may hold
+	txns := make([]SignedTxn, 11)
+	for i := range txns {
+		txns[i] = testTxn(t, "alice", "carol", 1, 1, uint64(i))
+	}
+	oversized, err := NewBlock("test", c0, 1, testBlockTime(1), producer, 1, 0, Hash{}, txns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oversized = sign(oversized, func(h *BlockHeader) {})
+
+	tests := []struct {
+		name    string
+		blocks  []Block
+		stored  []Block
+		dropped []Block
+	}{
+		{
+			name:    "cheap side block",
+			blocks:  []Block{sign(branches.blocks["b1"], func(h *BlockHeader) { h.Difficulty = 0 })},
+			dropped: []Block{sign(branches.blocks["b1"], func(h *BlockHeader) { h.Difficulty = 0 })},
+		},
+		{
+			name:    "wrong difficulty",
+			blocks:  []Block{sign(branches.blocks["b1"], func(h *BlockHeader) { h.Difficulty = 2 })},
+			dropped: []Block{sign(branches.blocks["b1"], func(h *BlockHeader) { h.Difficulty = 2 })},
+		},
+		{
+			name:    "unknown parent",
+			blocks:  []Block{sign(branches.blocks["b1"], func(h *BlockHeader) { h.Parent = Hash{1} })},
+			dropped: []Block{sign(branches.blocks["b1"], func(h *BlockHeader) { h.Parent = Hash{1} })},
+		},
+		{
+			name:    "invalid side block is dropped with its descendants",
+			blocks:  []Block{badRoot, onBadRoot},
+			dropped: []Block{badRoot, onBadRoot},
+		},
+		{
+			name:    "oversized side block",
+			blocks:  []Block{oversized},
+			dropped: []Block{oversized},
+		},
+		{
+			name:   "valid side block is stored",
+			blocks: []Block{branches.blocks["b1"]},
+			stored: []Block{branches.blocks["b1"]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestState(t, nil, "alice", "dave", "erin")
+			for _, name := range []string{"c0", "a1"} {
+				if _, err := s.AddBlock(branches.blocks[name]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, b := range tt.blocks {
+				s.AddBlock(b)
+			}
+
+			for _, b := range tt.stored {
+				if _, ok := s.blocks[blockHash(t, b)]; !ok {
+					t.Errorf("block %d %x is not stored", b.Header.Number, blockHash(t, b))
+				}
+			}
+			for _, b := range tt.dropped {
+				hash := blockHash(t, b)
+				if _, ok := s.blocks[hash]; ok {
+					t.Errorf("block %d %x is stored", b.Header.Number, hash)
+				}
+				if _, ok := s.children[hash]; ok {
+					t.Errorf("block %d %x still has children", b.Header.Number, hash)
+				}
+			}
+
+			if hash := blockHash(t, branches.blocks["a1"]); s.LatestBlockHash() != hash {
+				t.Errorf("latest block is %x, want a1 %x", s.LatestBlockHash(), hash)
+			}
+			if children := s.children[c0]; len(children) != 1+len(tt.stored) {
+				t.Errorf("block c0 has %d children, want %d", len(children), 1+len(tt.stored))
+			}
+		})
+	}
+}
+
+func TestNewStateFromDiskLineTooLong(t *testing.T) {
+	s, dataDir := newTestState(t, nil, "alice")
+	produceTestBlock(t, s, "producer")
+	s.Close()
+
+	f, err := os.OpenFile(getBlocksDbFilePath(dataDir), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(bytes.Repeat([]byte("x"), maxBlockFsSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := NewStateFromDisk(dataDir); err == nil {
+		t.Errorf("got no error for a db line longer than %d bytes", maxBlockFsSize)
+	}
+}
+
+func TestAddBlockSigners(t *testing.T) {
+	_, signer := testKey("producer")
+
+	s, _ := newTestState(t, []Account{signer}, "alice")
+	produceTestBlock(t, s, "producer")
+
+	tests := []struct {
+		name     string
+		producer string
+		valid    bool
+	}{
+		{"authorized signer", "producer", true},
+		{"outsider", "outsider", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privKey, account := testKey(tt.producer)
+
+			// a side block next to the latest block
+			parent := s.blocks[s.LatestBlockHash()].block.Header.Parent
+			b, err := NewBlock("test", parent, 0, testBlockTime(1), account, 1, 0, Hash{}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err = SignBlock(b, privKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.AddBlock(b)
+			if (err == nil) != tt.valid {
+				t.Errorf("got error %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+
+	if signers := (branchChain{s, s.LatestBlockHash()}).Signers(); len(signers) != 1 || signers[0] != signer {
+		t.Errorf("got signers %v, want %v", signers, []Account{signer})
+	}
+}
+
+func TestRecentHeaders(t *testing.T) {
+	branches := newTestBranches(t)
+	s, _ := newTestState(t, nil, "alice", "dave", "erin")
+	for _, name := range []string{"c0", "a1", "a2", "b1", "b2"} {
+		if _, err := s.AddBlock(branches.blocks[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	maxInt := int(^uint(0) >> 1)
+	tests := []struct {
+		name    string
+		tip     string
+		n       int
+		headers []string
+	}{
+		{"side branch", "b2", 2, []string{"b1", "b2"}},
+		{"whole branch", "b2", 3, []string{"c0", "b1", "b2"}},
+		{"more than the branch", "b2", 10, []string{"c0", "b1", "b2"}},
+		{"huge retarget interval", "a2", maxInt, []string{"c0", "a1", "a2"}},
+		{"inner block", "b1", 1, []string{"b1"}},
+		{"canonical chain", "a2", 2, []string{"a1", "a2"}},
+		{"none", "b2", 0, []string{}},
+		{"negative", "b2", -1, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := branchChain{s, blockHash(t, branches.blocks[tt.tip])}.RecentHeaders(tt.n)
+			if len(headers) != len(tt.headers) {
+				t.Fatalf("got %d headers, want %d", len(headers), len(tt.headers))
+			}
+			for i, name := range tt.headers {
+				if headers[i] != branches.blocks[name].Header {
+					t.Errorf("header %d is block %d, want %s", i, headers[i].Number, name)
+				}
+			}
+
+			// branch b has no more work, so the canonical chain ends with a2
+			if tt.tip == "a2" {
+				if canonical := s.RecentHeaders(tt.n); len(canonical) != len(headers) || canonical[len(canonical)-1] != headers[len(headers)-1] {
+					t.Errorf("got canonical headers %v, want %v", canonical, headers)
+				}
+			}
+		})
+	}
+}
@@ -1,21 +1,49 @@
 package database
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 )
 
+// maxBlockFsSize is the largest block the db file can hold on one line
+const maxBlockFsSize = 64 * 1024 * 1024
+
 func initDataDirIfNotExists(path string) error {
 	if exists(getGenesisJsonFilePath(path)) {
 		return nil
 	}
 
+	return initDataDir(path, []byte(genesisJSON))
+}
+
+// InitDataDir validates the given genesis and installs it together
+// with an empty blockchain into a new data dir.
+// It returns the hash of the installed genesis.
+func InitDataDir(path string, genesisContent []byte) (Hash, error) {
+	if exists(getGenesisJsonFilePath(path)) {
+		return Hash{}, fmt.Errorf("data dir %s is already initialized", path)
+	}
+
+	gen, err := parseGenesis(genesisContent)
+	if err != nil {
+		return Hash{}, fmt.Errorf("invalid genesis: %s", err)
+	}
+
+	if err := initDataDir(path, genesisContent); err != nil {
+		return Hash{}, err
+	}
+
+	return gen.Hash()
+}
+
+func initDataDir(path string, genesisContent []byte) error {
 	if err := os.MkdirAll(getDatabaseDirPath(path), os.ModePerm); err != nil {
 		return err
 	}
 
-	if err := writeGenesisToDisk(getGenesisJsonFilePath(path)); err != nil {
+	if err := writeGenesisToDisk(getGenesisJsonFilePath(path), genesisContent); err != nil {
 		return err
 	}
 
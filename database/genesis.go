@@ -1,20 +1,59 @@
 package database
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"time"
 )
 
 var genesisJSON = `{
     "genesis_time": "2021-12-17T00:00:00.000000000Z",
     "chain_id": "nefoli",
-    "balances": {
-        "dibek": 1000000
+    "balances": {},
+    "consensus": {
+        "engine": "pow",
+        "max_block_txns": 1000,
+        "difficulty": 65536,
+        "block_interval": 15,
+        "retarget_interval": 20,
+        "block_reward": 100,
+        "halving_interval": 210000,
+        "max_supply": 42000000,
+        "max_future_block_time": 120
     }
   }`
 
 type genesis struct {
-	Balances map[Account]uint `json:"balances"`
+	Time      time.Time        `json:"genesis_time"`
+	ChainID   string           `json:"chain_id"`
+	Balances  map[Account]uint `json:"balances"`
+	Consensus ConsensusParams  `json:"consensus"`
+}
+
+// ConsensusParams stores the rules every node of the chain must agree on.
+// Engine names the registered engine producing and verifying blocks.
+// With proof of work Difficulty is the expected number of hashes needed to
+// mine the first blocks. Every RetargetInterval blocks the difficulty is
+// adjusted so that blocks are mined every BlockInterval seconds on average.
+// With proof of authority the Signers take turns producing a block
+// every BlockInterval seconds and vote other signers in and out.
+// The producer of a block is rewarded with BlockReward, which is
+// halved every HalvingInterval blocks until MaxSupply is minted.
+// A block may be dated at most MaxFutureBlockTime seconds ahead of local time.
+type ConsensusParams struct {
+	Engine             string    `json:"engine"`
+	Signers            []Account `json:"signers,omitempty"`
+	MaxBlockTxns       uint      `json:"max_block_txns"`
+	Difficulty         uint64    `json:"difficulty,omitempty"`
+	BlockInterval      uint64    `json:"block_interval"`
+	RetargetInterval   uint64    `json:"retarget_interval,omitempty"`
+	BlockReward        uint      `json:"block_reward"`
+	HalvingInterval    uint64    `json:"halving_interval"`
+	MaxSupply          uint      `json:"max_supply"`
+	MaxFutureBlockTime uint64    `json:"max_future_block_time"`
 }
 
 func loadGenesis(path string) (genesis, error) {
@@ -23,15 +62,87 @@ func loadGenesis(path string) (genesis, error) {
 		return genesis{}, err
 	}
 
-	var loadedGenesis genesis
-	err = json.Unmarshal(content, &loadedGenesis)
+	loadedGenesis, err := parseGenesis(content)
 	if err != nil {
-		return genesis{}, err
+		return genesis{}, fmt.Errorf("invalid genesis %s: %s", path, err)
 	}
 
 	return loadedGenesis, nil
 }
 
-func writeGenesisToDisk(path string) error {
-	return ioutil.WriteFile(path, []byte(genesisJSON), 0644)
+// parseGenesis unmarshals and validates the genesis content.
+// Unknown keys are rejected so that a misspelled param is not ignored.
+func parseGenesis(content []byte) (genesis, error) {
+	var gen genesis
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&gen)
+	if err != nil {
+		return genesis{}, err
+	}
+
+	if decoder.More() {
+		return genesis{}, fmt.Errorf("unexpected content after the genesis")
+	}
+
+	if gen.Time.IsZero() {
+		return genesis{}, fmt.Errorf("genesis_time is missing")
+	}
+
+	if gen.ChainID == "" {
+		return genesis{}, fmt.Errorf("chain_id is missing")
+	}
+
+	supply := uint(0)
+	for account, balance := range gen.Balances {
+		if err := account.Validate(); err != nil {
+			return genesis{}, fmt.Errorf("%s, legacy accounts can be converted with paisa migrate", err)
+		}
+		if supply+balance < supply {
+			return genesis{}, fmt.Errorf("genesis balances overflow the supply")
+		}
+		supply += balance
+	}
+
+	// a block holds the coinbase and at least one txn
+	if gen.Consensus.MaxBlockTxns < 2 {
+		return genesis{}, fmt.Errorf("consensus max_block_txns must be at least 2")
+	}
+
+	if gen.Consensus.MaxFutureBlockTime == 0 {
+		return genesis{}, fmt.Errorf("consensus max_future_block_time must be greater than 0")
+	}
+
+	if gen.Consensus.BlockInterval == 0 {
+		return genesis{}, fmt.Errorf("consensus block_interval must be greater than 0")
+	}
+
+	// the engine checks the params it depends on
+	if _, err := newEngine(gen.Consensus); err != nil {
+		return genesis{}, err
+	}
+
+	if gen.Consensus.HalvingInterval == 0 {
+		return genesis{}, fmt.Errorf("consensus halving_interval must be greater than 0")
+	}
+
+	if supply > gen.Consensus.MaxSupply {
+		return genesis{}, fmt.Errorf("genesis balances of %d exceed the consensus max_supply of %d", supply, gen.Consensus.MaxSupply)
+	}
+
+	return gen, nil
+}
+
+// Hash returns the sha256 hash of the canonical encoding of the genesis.
+// Formatting of the genesis file does not change the hash.
+func (g genesis) Hash() (Hash, error) {
+	genesisJson, err := json.Marshal(g)
+	if err != nil {
+		return Hash{}, err
+	}
+	return sha256.Sum256(genesisJson), nil
+}
+
+func writeGenesisToDisk(path string, content []byte) error {
+	return ioutil.WriteFile(path, content, 0644)
 }
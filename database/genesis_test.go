@@ -0,0 +1,51 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseGenesisSupply(t *testing.T) {
+	_, alice := testKey("alice")
+	_, bob := testKey("bob")
+
+	tests := []struct {
+		name      string
+		balances  map[Account]uint
+		maxSupply uint
+		valid     bool
+	}{
+		{"below max supply", map[Account]uint{alice: 2, bob: 2}, 5, true},
+		{"at max supply", map[Account]uint{alice: 2, bob: 3}, 5, true},
+		{"above max supply", map[Account]uint{alice: 3, bob: 3}, 5, false},
+		{"overflow", map[Account]uint{alice: 1 << 63, bob: 1 << 63}, 5, false},
+		{"overflow below max supply", map[Account]uint{alice: ^uint(0), bob: 1}, ^uint(0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := json.Marshal(genesis{
+				Time:     testGenesisTime,
+				ChainID:  "test",
+				Balances: tt.balances,
+				Consensus: ConsensusParams{
+					Engine:             testEngine,
+					MaxBlockTxns:       10,
+					Difficulty:         1,
+					BlockInterval:      1,
+					BlockReward:        10,
+					HalvingInterval:    100,
+					MaxSupply:          tt.maxSupply,
+					MaxFutureBlockTime: 120,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := parseGenesis(content); (err == nil) != tt.valid {
+				t.Errorf("got error %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testEngine is registered for the tests of the database, which can
+// not import the engines of the consensus package. Every block has the
+// difficulty of the genesis and, with signers, is produced by a signer.
+const testEngine = "test"
+
+type testConsensus struct {
+	params ConsensusParams
+}
+
+func init() {
+	RegisterEngine(testEngine, func(params ConsensusParams) (Engine, error) {
+		return testConsensus{params}, nil
+	})
+}
+
+func (e testConsensus) PrepareHeader(chain ChainReader, header *BlockHeader, now uint64) error {
+	header.Time = now
+	if median := chain.MedianTimePast(); chain.NextBlockNumber() > 0 && now <= median {
+		header.Time = median + 1
+	}
+	header.Difficulty = e.params.Difficulty
+	return nil
+}
+
+func (e testConsensus) Seal(ctx context.Context, b Block, privKey ed25519.PrivateKey) (Block, error) {
+	return SignBlock(b, privKey)
+}
+
+func (e testConsensus) VerifySeal(b Block) error {
+	return nil
+}
+
+func (e testConsensus) VerifyHeader(chain ChainReader, header BlockHeader) error {
+	if header.Difficulty != e.params.Difficulty {
+		return fmt.Errorf("block %d has difficulty %d, expected %d", header.Number, header.Difficulty, e.params.Difficulty)
+	}
+
+	signers := chain.Signers()
+	if len(signers) == 0 {
+		return nil
+	}
+	for _, signer := range signers {
+		if signer == header.Producer {
+			return nil
+		}
+	}
+	return fmt.Errorf("block %d was produced by %s which is not an authorized signer", header.Number, header.Producer)
+}
+
+func (e testConsensus) Finalize(chain ChainReader, header BlockHeader) uint {
+	return BlockReward(header.Number, chain.Supply(), chain.Consensus())
+}
+
+// testGenesisTime is the genesis time of test chains, blocks
+// are dated a second apart after it with testBlockTime
+var testGenesisTime = time.Date(2021, 12, 17, 0, 0, 0, 0, time.UTC)
+
+func testBlockTime(number uint64) uint64 {
+	return uint64(testGenesisTime.Unix()) + number + 1
+}
+
+// testKey returns the key and account of a test user
+func testKey(name string) (ed25519.PrivateKey, Account) {
+	seed := sha256.Sum256([]byte(name))
+	privKey := ed25519.NewKeyFromSeed(seed[:])
+	return privKey, NewAccountFromPubKey(privKey.Public().(ed25519.PublicKey))
+}
+
+// newTestState returns the state of a new data dir whose genesis
+// funds the given users with 1000 paisa each
+func newTestState(t *testing.T, signers []Account, funded ...string) (*State, string) {
+	t.Helper()
+
+	balances := make(map[Account]uint)
+	for _, name := range funded {
+		_, account := testKey(name)
+		balances[account] = 1000
+	}
+
+	content, err := json.Marshal(genesis{
+		Time:     testGenesisTime,
+		ChainID:  "test",
+		Balances: balances,
+		Consensus: ConsensusParams{
+			Engine:             testEngine,
+			Signers:            signers,
+			MaxBlockTxns:       10,
+			Difficulty:         1,
+			BlockInterval:      1,
+			BlockReward:        10,
+			HalvingInterval:    100,
+			MaxSupply:          1000000,
+			MaxFutureBlockTime: 120,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := t.TempDir()
+	if _, err := InitDataDir(dataDir, content); err != nil {
+		t.Fatal(err)
+	}
+
+	return openTestState(t, dataDir), dataDir
+}
+
+// openTestState loads the state of the data dir, closing it after the test
+func openTestState(t *testing.T, dataDir string) *State {
+	t.Helper()
+
+	s, err := NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// testTxn returns a txn signed by the sender
+func testTxn(t *testing.T, from, to string, value, fee uint, nonce uint64) SignedTxn {
+	t.Helper()
+
+	privKey, fromAccount := testKey(from)
+	_, toAccount := testKey(to)
+	txn, err := NewSignedTxn(NewTxn("test", fromAccount, toAccount, value, fee, nonce, ""), privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txn
+}
+
+// produceTestBlock adds a block with the txns produced by the producer on
+// top of the latest block of the state and returns the block
+func produceTestBlock(t *testing.T, s *State, producer string, txns ...SignedTxn) Block {
+	t.Helper()
+
+	privKey, account := testKey(producer)
+	pending, err := s.NewPendingBlock(account, testBlockTime(s.NextBlockNumber()), txns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := SignBlock(pending, privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.AddBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// blockHash returns the hash of the block
+func blockHash(t *testing.T, b Block) Hash {
+	t.Helper()
+
+	hash, err := b.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
@@ -0,0 +1,81 @@
+package database
+
+import "fmt"
+
+// TxnLocation stores where a txn was included in the blockchain
+type TxnLocation struct {
+	BlockHash   Hash   `json:"block_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	Index       int    `json:"index"`
+}
+
+// txnRecord stores an included txn with its location
+type txnRecord struct {
+	txn      SignedTxn
+	location TxnLocation
+}
+
+// indexTxns adds every txn of the block to the txn index
+func indexTxns(index map[Hash]txnRecord, b Block, blockHash Hash) error {
+	for i, txn := range b.Txns {
+		txnHash, err := txn.Hash()
+		if err != nil {
+			return err
+		}
+
+		index[txnHash] = txnRecord{txn, TxnLocation{blockHash, b.Header.Number, i}}
+	}
+	return nil
+}
+
+// unindexTxns removes every txn of the block from the txn index
+func unindexTxns(index map[Hash]txnRecord, b Block, blockHash Hash) error {
+	for _, txn := range b.Txns {
+		txnHash, err := txn.Hash()
+		if err != nil {
+			return err
+		}
+
+		if index[txnHash].location.BlockHash == blockHash {
+			delete(index, txnHash)
+		}
+	}
+	return nil
+}
+
+// GetTxn returns the txn with the given hash and where it was included
+func (s *State) GetTxn(hash Hash) (SignedTxn, TxnLocation, bool) {
+	record, ok := s.txnIndex[hash]
+	return record.txn, record.location, ok
+}
+
+// Confirmations returns the number of blocks from the block
+// at the given number up to and including the latest block
+func (s *State) Confirmations(blockNumber uint64) uint64 {
+	if !s.hasGenesisBlock || blockNumber > s.latestBlock.Header.Number {
+		return 0
+	}
+	return s.latestBlock.Header.Number - blockNumber + 1
+}
+
+// TxnProof returns the proof that the included txn with the
+// given hash is part of its block, verifiable against the header
+func (s *State) TxnProof(hash Hash) (TxnProof, error) {
+	record, ok := s.txnIndex[hash]
+	if !ok {
+		return TxnProof{}, fmt.Errorf("txn %x not found", hash)
+	}
+
+	b := s.blocks[record.location.BlockHash].block
+	authHash, err := record.txn.AuthHash()
+	if err != nil {
+		return TxnProof{}, err
+	}
+
+	path, err := merklePath(b.Txns, record.location.Index)
+	if err != nil {
+		return TxnProof{}, err
+	}
+
+	return TxnProof{hash, authHash, b.Header, path}, nil
+}
@@ -0,0 +1,327 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MempoolLimits bounds the txns a node keeps pending: their number, their
+// encoded size in bytes, the number of txns pending per sender and how long
+// a txn may wait for a block. A limit of 0 is not enforced.
+type MempoolLimits struct {
+	MaxTxns       int
+	MaxBytes      int
+	MaxPerAccount int
+	Expiry        time.Duration
+}
+
+// DefaultMempoolLimits are the mempool limits of a node unless set otherwise
+var DefaultMempoolLimits = MempoolLimits{
+	MaxTxns:       5000,
+	MaxBytes:      5 * 1024 * 1024,
+	MaxPerAccount: 64,
+	Expiry:        3 * time.Hour,
+}
+
+// exceeded checks if a mempool of count txns and size bytes is over the limits
+func (l MempoolLimits) exceeded(count, size int) bool {
+	return (l.MaxTxns > 0 && count > l.MaxTxns) || (l.MaxBytes > 0 && size > l.MaxBytes)
+}
+
+// pendingTxn is a txn in the mempool with its hash,
+// its encoded size and the time it was added
+type pendingTxn struct {
+	txn   SignedTxn
+	hash  Hash
+	size  int
+	added time.Time
+}
+
+// droppedTxn is a txn removed from the mempool without being included
+type droppedTxn struct {
+	pendingTxn
+	reason error
+}
+
+// newPendingTxn returns the txn as added to the mempool at the given time
+func newPendingTxn(txn SignedTxn, added time.Time) (pendingTxn, error) {
+	txnHash, err := txn.Hash()
+	if err != nil {
+		return pendingTxn{}, err
+	}
+
+	txnJson, err := json.Marshal(txn)
+	if err != nil {
+		return pendingTxn{}, err
+	}
+
+	return pendingTxn{txn, txnHash, len(txnJson), added}, nil
+}
+
+// mempool holds the txns waiting to be included in a block in the order
+// they were added. pending is the state with all of them applied, so that
+// a new txn is validated on top of the txns before it. It is only created
+// once a txn is added.
+type mempool struct {
+	txns     []pendingTxn
+	hashes   map[Hash]struct{}
+	accounts map[Account]int
+	size     int
+	pending  *State
+}
+
+// newMempool returns an empty mempool
+func newMempool() *mempool {
+	return &mempool{make([]pendingTxn, 0), make(map[Hash]struct{}), make(map[Account]int), 0, nil}
+}
+
+// add applies the txn to the pending state on top of
+// the given state and adds it to the mempool
+func (m *mempool) add(p pendingTxn, s *State) error {
+	if m.pending == nil {
+		pending := s.copy()
+		m.pending = &pending
+	}
+
+	if err := applyTxn(p.txn, m.pending); err != nil {
+		return err
+	}
+
+	m.txns = append(m.txns, p)
+	m.hashes[p.hash] = struct{}{}
+	m.accounts[p.txn.From]++
+	m.size += p.size
+	return nil
+}
+
+// find returns the index of the pending txn of the sender with the nonce or -1
+func (m *mempool) find(from Account, nonce uint64) int {
+	for i, p := range m.txns {
+		if p.txn.From == from && p.txn.Nonce == nonce {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetMempoolLimits sets the limits of the mempool of the node
+func (s *State) SetMempoolLimits(limits MempoolLimits) {
+	s.mempoolLimits = limits
+}
+
+// AddPendingTxn validates the txn against the state with the pending txns
+// applied and adds it to the mempool. A txn with the nonce of a pending txn
+// of the same sender replaces that txn if it pays a higher fee. When the
+// mempool is full the pending txns with the lowest fees are evicted for it.
+func (s *State) AddPendingTxn(txn SignedTxn) (Hash, error) {
+	p, err := newPendingTxn(txn, time.Now())
+	if err != nil {
+		return Hash{}, err
+	}
+
+	s.ExpirePendingTxns(p.added)
+
+	if _, ok := s.txnMempool.hashes[p.hash]; ok {
+		return Hash{}, fmt.Errorf("txn %x is already pending", p.hash)
+	}
+
+	if record, ok := s.txnIndex[p.hash]; ok {
+		return Hash{}, fmt.Errorf("txn %x is already included in block %d", p.hash, record.location.BlockNumber)
+	}
+
+	limits := s.mempoolLimits
+	if limits.MaxBytes > 0 && p.size > limits.MaxBytes {
+		return Hash{}, fmt.Errorf("txn of %d bytes is larger than the mempool of %d bytes", p.size, limits.MaxBytes)
+	}
+
+	replaced := s.txnMempool.find(txn.From, txn.Nonce)
+	if replaced < 0 && limits.MaxPerAccount > 0 && s.txnMempool.accounts[txn.From] >= limits.MaxPerAccount {
+		return Hash{}, fmt.Errorf("%s already has %d pending txns, the maximum is %d", txn.From, s.txnMempool.accounts[txn.From], limits.MaxPerAccount)
+	}
+
+	if replaced >= 0 {
+		if old := s.txnMempool.txns[replaced]; txn.Fee <= old.txn.Fee {
+			return Hash{}, fmt.Errorf("txn %x with nonce %d of %s is already pending with fee %d, a replacement must pay a higher fee", old.hash, txn.Nonce, txn.From, old.txn.Fee)
+		}
+	}
+
+	// a new txn which fits is applied on top of the pending txns
+	if replaced < 0 && !limits.exceeded(len(s.txnMempool.txns)+1, s.txnMempool.size+p.size) {
+		if err := s.txnMempool.add(p, s); err != nil {
+			return Hash{}, err
+		}
+		s.txnFates.forget(p.hash, false)
+		return p.hash, nil
+	}
+
+	// otherwise the mempool is rebuilt with the replacement
+	// in place of the replaced txn or without the evicted txns
+	txns := make([]pendingTxn, len(s.txnMempool.txns), len(s.txnMempool.txns)+1)
+	copy(txns, s.txnMempool.txns)
+	dropped := make([]droppedTxn, 0)
+	if replaced >= 0 {
+		dropped = append(dropped, droppedTxn{txns[replaced], fmt.Errorf("replaced by txn %x with a higher fee", p.hash)})
+		txns[replaced] = p
+	} else {
+		txns = append(txns, p)
+	}
+
+	txns, evicted, err := s.evictPendingTxns(txns, p)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	pool, invalid := s.fillMempool(txns)
+	for _, d := range invalid {
+		if d.hash == p.hash {
+			return Hash{}, d.reason
+		}
+	}
+
+	s.txnMempool = pool
+	s.dropPendingTxns(append(append(dropped, evicted...), invalid...))
+	s.txnFates.forget(p.hash, false)
+	return p.hash, nil
+}
+
+// evictPendingTxns removes the txns with the lowest fees until the txns fit the
+// mempool limits. Only the last pending txn of a sender is evicted so that its
+// other txns keep their nonces in order, and the txns of the sender of the new
+// txn are kept. The new txn is refused if it does not pay more than the txns
+// it would evict.
+func (s *State) evictPendingTxns(txns []pendingTxn, p pendingTxn) ([]pendingTxn, []droppedTxn, error) {
+	size := 0
+	for _, pending := range txns {
+		size += pending.size
+	}
+
+	evicted := make([]droppedTxn, 0)
+	for s.mempoolLimits.exceeded(len(txns), size) {
+		last := make(map[Account]int)
+		for i, pending := range txns {
+			if pending.txn.From != p.txn.From {
+				last[pending.txn.From] = i
+			}
+		}
+
+		victim := -1
+		for _, i := range last {
+			if victim < 0 || txns[i].txn.Fee < txns[victim].txn.Fee ||
+				(txns[i].txn.Fee == txns[victim].txn.Fee && txns[i].added.After(txns[victim].added)) {
+				victim = i
+			}
+		}
+
+		if victim < 0 {
+			return nil, nil, fmt.Errorf("mempool is full")
+		}
+		if txns[victim].txn.Fee >= p.txn.Fee {
+			return nil, nil, fmt.Errorf("mempool is full, txn must pay a fee higher than %d", txns[victim].txn.Fee)
+		}
+
+		evicted = append(evicted, droppedTxn{txns[victim], fmt.Errorf("evicted from the full mempool by txn %x with a higher fee", p.hash)})
+		size -= txns[victim].size
+		txns = append(txns[:victim:victim], txns[victim+1:]...)
+	}
+	return txns, evicted, nil
+}
+
+// ExpirePendingTxns drops the txns which have been pending for longer than
+// the expiry and returns the number of dropped txns. The mempool is rebuilt
+// without them only if any expired.
+func (s *State) ExpirePendingTxns(now time.Time) int {
+	if s.mempoolLimits.Expiry <= 0 {
+		return 0
+	}
+
+	txns := make([]pendingTxn, 0, len(s.txnMempool.txns))
+	expired := make([]droppedTxn, 0)
+	for _, p := range s.txnMempool.txns {
+		if now.Sub(p.added) > s.mempoolLimits.Expiry {
+			expired = append(expired, droppedTxn{p, fmt.Errorf("expired after %s in the mempool", s.mempoolLimits.Expiry)})
+			continue
+		}
+		txns = append(txns, p)
+	}
+
+	if len(expired) == 0 {
+		return 0
+	}
+
+	pool, invalid := s.fillMempool(txns)
+	s.txnMempool = pool
+	s.dropPendingTxns(append(expired, invalid...))
+	return len(expired) + len(invalid)
+}
+
+// fillMempool returns a mempool with the txns which can be applied in order on
+// top of the state and the txns which were dropped because they can not
+func (s *State) fillMempool(txns []pendingTxn) (*mempool, []droppedTxn) {
+	pool := newMempool()
+	dropped := make([]droppedTxn, 0)
+	for _, p := range txns {
+		if err := pool.add(p, s); err != nil {
+			dropped = append(dropped, droppedTxn{p, err})
+		}
+	}
+	return pool, dropped
+}
+
+// dropPendingTxns records the txns removed from the mempool
+func (s *State) dropPendingTxns(dropped []droppedTxn) {
+	for _, d := range dropped {
+		fmt.Printf("Dropped pending txn %x: %s\n", d.hash, d.reason)
+		s.txnFates.drop(d.hash, d.reason)
+	}
+}
+
+// PendingTxns returns up to max pending txns in the order they were added.
+// Expired txns are only dropped by ExpirePendingTxns and AddPendingTxn, so a
+// producer expires the txns first.
+func (s *State) PendingTxns(max int) []SignedTxn {
+	if max > len(s.txnMempool.txns) {
+		max = len(s.txnMempool.txns)
+	}
+
+	txns := make([]SignedTxn, max)
+	for i := range txns {
+		txns[i] = s.txnMempool.txns[i].txn
+	}
+	return txns
+}
+
+// PendingCount returns the number of txns in the mempool
+func (s *State) PendingCount() int {
+	return len(s.txnMempool.txns)
+}
+
+// refillMempool keeps the given txns in the mempool which are not
+// included in the chain, have not expired and can still be applied
+func (s *State) refillMempool(txns []pendingTxn) error {
+	now := time.Now()
+	pending := make([]pendingTxn, 0, len(txns))
+	seen := make(map[Hash]struct{}, len(txns))
+	dropped := make([]droppedTxn, 0)
+	for _, p := range txns {
+		if _, ok := s.txnIndex[p.hash]; ok {
+			continue
+		}
+
+		if _, ok := seen[p.hash]; ok {
+			continue
+		}
+		seen[p.hash] = struct{}{}
+
+		if s.mempoolLimits.Expiry > 0 && now.Sub(p.added) > s.mempoolLimits.Expiry {
+			dropped = append(dropped, droppedTxn{p, fmt.Errorf("expired after %s in the mempool", s.mempoolLimits.Expiry)})
+			continue
+		}
+		pending = append(pending, p)
+	}
+
+	pool, invalid := s.fillMempool(pending)
+	s.txnMempool = pool
+	s.dropPendingTxns(append(dropped, invalid...))
+	return nil
+}
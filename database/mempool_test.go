@@ -0,0 +1,223 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+// checkMempool checks that the mempool holds the pending txns
+// in order and that the dropped txns are reported as dropped
+func checkMempool(t *testing.T, s *State, pending, dropped []SignedTxn) {
+	t.Helper()
+
+	txns := s.PendingTxns(len(s.txnMempool.txns) + 1)
+	if len(txns) != len(pending) {
+		t.Fatalf("got %d pending txns, want %d", len(txns), len(pending))
+	}
+	for i, txn := range txns {
+		if got, want := txnHash(t, txn), txnHash(t, pending[i]); got != want {
+			t.Errorf("pending txn %d is %x, want %x", i, got, want)
+		}
+	}
+
+	for _, txn := range dropped {
+		hash := txnHash(t, txn)
+		if status := s.TxnStatus(hash); status.Status != TxnDropped || status.Reason == "" || !status.Final() {
+			t.Errorf("txn %x has status %q with reason %q, want %q", hash, status.Status, status.Reason, TxnDropped)
+		}
+	}
+}
+
+// txnHash returns the hash of the txn
+func txnHash(t *testing.T, txn SignedTxn) Hash {
+	t.Helper()
+
+	hash, err := txn.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestAddPendingTxn(t *testing.T) {
+	alice0 := testTxn(t, "alice", "dave", 500, 1, 0)
+	alice0Fee2 := testTxn(t, "alice", "dave", 500, 2, 0)
+	alice0Other := testTxn(t, "alice", "dave", 600, 1, 0)
+	alice0Larger := testTxn(t, "alice", "dave", 700, 2, 0)
+	alice1 := testTxn(t, "alice", "dave", 400, 1, 1)
+	alice1Fee2 := testTxn(t, "alice", "dave", 400, 2, 1)
+	alice1Fee5 := testTxn(t, "alice", "dave", 400, 5, 1)
+	bob0 := testTxn(t, "bob", "dave", 10, 3, 0)
+	carol0 := testTxn(t, "carol", "dave", 10, 2, 0)
+	carol0Fee1 := testTxn(t, "carol", "dave", 10, 1, 0)
+	aliceGap := testTxn(t, "alice", "dave", 10, 1, 1)
+
+	tests := []struct {
+		name    string
+		limits  MempoolLimits
+		txns    []SignedTxn
+		valid   []bool
+		pending []SignedTxn
+		dropped []SignedTxn
+	}{
+		{
+			name:    "txns fit",
+			limits:  MempoolLimits{MaxTxns: 3},
+			txns:    []SignedTxn{alice0, bob0, alice1},
+			valid:   []bool{true, true, true},
+			pending: []SignedTxn{alice0, bob0, alice1},
+		},
+		{
+			name:    "nonce gap",
+			txns:    []SignedTxn{aliceGap},
+			valid:   []bool{false},
+			pending: []SignedTxn{},
+		},
+		{
+			name:    "already pending",
+			txns:    []SignedTxn{alice0, alice0},
+			valid:   []bool{true, false},
+			pending: []SignedTxn{alice0},
+		},
+		{
+			name:    "replace by fee",
+			txns:    []SignedTxn{alice0, bob0, alice0Fee2},
+			valid:   []bool{true, true, true},
+			pending: []SignedTxn{alice0Fee2, bob0},
+			dropped: []SignedTxn{alice0},
+		},
+		{
+			name:    "replacement must pay a higher fee",
+			txns:    []SignedTxn{alice0, alice0Other},
+			valid:   []bool{true, false},
+			pending: []SignedTxn{alice0},
+		},
+		{
+			name:    "replacement drops the txns it no longer funds",
+			txns:    []SignedTxn{alice0, alice1, bob0, alice0Larger},
+			valid:   []bool{true, true, true, true},
+			pending: []SignedTxn{alice0Larger, bob0},
+			dropped: []SignedTxn{alice0, alice1},
+		},
+		{
+			name:    "invalid replacement keeps the mempool",
+			txns:    []SignedTxn{alice0Fee2, alice1, testTxn(t, "alice", "dave", 1000, 3, 0)},
+			valid:   []bool{true, true, false},
+			pending: []SignedTxn{alice0Fee2, alice1},
+		},
+		{
+			name:    "lowest fee is evicted",
+			limits:  MempoolLimits{MaxTxns: 2},
+			txns:    []SignedTxn{alice0, bob0, carol0},
+			valid:   []bool{true, true, true},
+			pending: []SignedTxn{bob0, carol0},
+			dropped: []SignedTxn{alice0},
+		},
+		{
+			name:    "full mempool refuses a lower fee",
+			limits:  MempoolLimits{MaxTxns: 2},
+			txns:    []SignedTxn{alice0Fee2, bob0, carol0Fee1},
+			valid:   []bool{true, true, false},
+			pending: []SignedTxn{alice0Fee2, bob0},
+		},
+		{
+			name:    "only the last txn of a sender is evicted",
+			limits:  MempoolLimits{MaxTxns: 2},
+			txns:    []SignedTxn{alice0, alice1Fee2, bob0},
+			valid:   []bool{true, true, true},
+			pending: []SignedTxn{alice0, bob0},
+			dropped: []SignedTxn{alice1Fee2},
+		},
+		{
+			name:    "txns of the sender are not evicted",
+			limits:  MempoolLimits{MaxTxns: 1},
+			txns:    []SignedTxn{alice0, alice1Fee5},
+			valid:   []bool{true, false},
+			pending: []SignedTxn{alice0},
+		},
+		{
+			name:    "limit per account",
+			limits:  MempoolLimits{MaxPerAccount: 1},
+			txns:    []SignedTxn{alice0, alice1, bob0, alice0Fee2},
+			valid:   []bool{true, false, true, true},
+			pending: []SignedTxn{alice0Fee2, bob0},
+			dropped: []SignedTxn{alice0},
+		},
+		{
+			name:    "txn larger than the mempool",
+			limits:  MempoolLimits{MaxBytes: 10},
+			txns:    []SignedTxn{alice0},
+			valid:   []bool{false},
+			pending: []SignedTxn{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestState(t, nil, "alice", "bob", "carol")
+			s.SetMempoolLimits(tt.limits)
+
+			for i, txn := range tt.txns {
+				if _, err := s.AddPendingTxn(txn); (err == nil) != tt.valid[i] {
+					t.Errorf("txn %d: got error %v, want valid %t", i, err, tt.valid[i])
+				}
+			}
+			checkMempool(t, s, tt.pending, tt.dropped)
+		})
+	}
+}
+
+func TestExpirePendingTxns(t *testing.T) {
+	alice0 := testTxn(t, "alice", "dave", 10, 1, 0)
+	alice1 := testTxn(t, "alice", "dave", 10, 1, 1)
+	bob0 := testTxn(t, "bob", "dave", 10, 1, 0)
+	carol0 := testTxn(t, "carol", "dave", 10, 1, 0)
+
+	tests := []struct {
+		name    string
+		expiry  time.Duration
+		ages    []time.Duration
+		pending []SignedTxn
+		dropped []SignedTxn
+	}{
+		{"none expired", time.Hour, []time.Duration{0, 0, 0, 0}, []SignedTxn{alice0, alice1, bob0, carol0}, nil},
+		{"old txns expire", time.Hour, []time.Duration{0, 0, 2 * time.Hour, 0}, []SignedTxn{alice0, alice1, carol0}, []SignedTxn{bob0}},
+		{"later nonces are dropped with an expired txn", time.Hour, []time.Duration{2 * time.Hour, 0, 0, 0}, []SignedTxn{bob0, carol0}, []SignedTxn{alice0, alice1}},
+		{"all expired", time.Hour, []time.Duration{2 * time.Hour, 2 * time.Hour, 2 * time.Hour, 2 * time.Hour}, []SignedTxn{}, []SignedTxn{alice0, alice1, bob0, carol0}},
+		{"no expiry", 0, []time.Duration{2 * time.Hour, 0, 2 * time.Hour, 0}, []SignedTxn{alice0, alice1, bob0, carol0}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestState(t, nil, "alice", "bob", "carol")
+			s.SetMempoolLimits(MempoolLimits{Expiry: tt.expiry})
+
+			now := time.Now()
+			for _, txn := range []SignedTxn{alice0, alice1, bob0, carol0} {
+				if _, err := s.AddPendingTxn(txn); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, age := range tt.ages {
+				s.txnMempool.txns[i].added = now.Add(-age)
+			}
+
+			if dropped := s.ExpirePendingTxns(now); dropped != len(tt.dropped) {
+				t.Errorf("got %d dropped txns, want %d", dropped, len(tt.dropped))
+			}
+			checkMempool(t, s, tt.pending, tt.dropped)
+		})
+	}
+
+	// adding a txn expires the txns without a call to ExpirePendingTxns
+	s, _ := newTestState(t, nil, "alice", "bob")
+	s.SetMempoolLimits(MempoolLimits{Expiry: time.Hour})
+	if _, err := s.AddPendingTxn(alice0); err != nil {
+		t.Fatal(err)
+	}
+	s.txnMempool.txns[0].added = time.Now().Add(-2 * time.Hour)
+	if _, err := s.AddPendingTxn(bob0); err != nil {
+		t.Fatal(err)
+	}
+	checkMempool(t, s, []SignedTxn{bob0}, []SignedTxn{alice0})
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// leaves and inner nodes of merkle trees are hashed with different
+// prefixes so that an inner node can not be passed off as a leaf
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// ProofStep is the sibling of a node on the path from a txn
+// to the txn root. Left is true if the sibling is the left node.
+type ProofStep struct {
+	Hash Hash `json:"hash"`
+	Left bool `json:"left"`
+}
+
+// TxnProof proves that a txn is part of the block with the given header.
+// The header alone is enough to verify the proof, the hash of the block
+// is the hash of its header.
+type TxnProof struct {
+	TxnHash  Hash        `json:"txn_hash"`
+	AuthHash Hash        `json:"auth_hash"`
+	Header   BlockHeader `json:"header"`
+	Path     []ProofStep `json:"path"`
+}
+
+// AuthHash returns the sha256 hash of the keys and signatures of the txn.
+// Together with the txn hash it commits a block to the signed txn.
+func (t SignedTxn) AuthHash() (Hash, error) {
+	auth, err := json.Marshal(struct {
+		PubKey   Bytes            `json:"pub_key"`
+		Sig      Bytes            `json:"signature"`
+		Multisig *MultisigAccount `json:"multisig,omitempty"`
+		Sigs     []Signature      `json:"signatures,omitempty"`
+	}{t.PubKey, t.Sig, t.Multisig, t.Sigs})
+	if err != nil {
+		return Hash{}, err
+	}
+	return sha256.Sum256(auth), nil
+}
+
+// merkleLeaf returns a leaf of a merkle tree committing to both hashes
+func merkleLeaf(key, value Hash) Hash {
+	data := make([]byte, 0, 1+2*len(Hash{}))
+	data = append(data, merkleLeafPrefix)
+	data = append(data, key[:]...)
+	data = append(data, value[:]...)
+	return sha256.Sum256(data)
+}
+
+// merkleNode returns the parent of two nodes in the merkle tree
+func merkleNode(left, right Hash) Hash {
+	data := make([]byte, 0, 1+2*len(Hash{}))
+	data = append(data, merkleNodePrefix)
+	data = append(data, left[:]...)
+	data = append(data, right[:]...)
+	return sha256.Sum256(data)
+}
+
+// merkleLevels returns every level of the merkle tree over the txns,
+// from the leaves up to the root. The last node of a level with an
+// odd number of nodes is moved up to the next level unchanged.
+func merkleLevels(txns []SignedTxn) ([][]Hash, error) {
+	leaves := make([]Hash, len(txns))
+	for i, txn := range txns {
+		txnHash, err := txn.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		authHash, err := txn.AuthHash()
+		if err != nil {
+			return nil, err
+		}
+		leaves[i] = merkleLeaf(txnHash, authHash)
+	}
+
+	levels := [][]Hash{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([]Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels, nil
+}
+
+// TxnRoot returns the root of the merkle tree over the txns.
+// A block without txns has an empty root.
+func TxnRoot(txns []SignedTxn) (Hash, error) {
+	if len(txns) == 0 {
+		return Hash{}, nil
+	}
+
+	levels, err := merkleLevels(txns)
+	if err != nil {
+		return Hash{}, err
+	}
+	return levels[len(levels)-1][0], nil
+}
+
+// merklePath returns the siblings on the path from the txn at the index to the root
+func merklePath(txns []SignedTxn, index int) ([]ProofStep, error) {
+	levels, err := merkleLevels(txns)
+	if err != nil {
+		return nil, err
+	}
+
+	path := []ProofStep{}
+	for _, level := range levels[:len(levels)-1] {
+		// a node without sibling moves up unchanged
+		if sibling := index ^ 1; sibling < len(level) {
+			path = append(path, ProofStep{level[sibling], sibling < index})
+		}
+		index /= 2
+	}
+	return path, nil
+}
+
+// Verify checks that the txn hash is committed to by the txn root of the header
+func (p TxnProof) Verify() error {
+	node := merkleLeaf(p.TxnHash, p.AuthHash)
+	for _, step := range p.Path {
+		if step.Left {
+			node = merkleNode(step.Hash, node)
+		} else {
+			node = merkleNode(node, step.Hash)
+		}
+	}
+
+	if node != p.Header.TxnRoot {
+		return fmt.Errorf("txn %x is not part of block %d with txn root %x", p.TxnHash, p.Header.Number, p.Header.TxnRoot)
+	}
+	return nil
+}
+
+// checkTxnRoot validates that the header of the block commits to its txns
+func checkTxnRoot(b Block) error {
+	root, err := TxnRoot(b.Txns)
+	if err != nil {
+		return err
+	}
+
+	if root != b.Header.TxnRoot {
+		return fmt.Errorf("block %d has txn root %x, its txns have root %x", b.Header.Number, b.Header.TxnRoot, root)
+	}
+	return nil
+}
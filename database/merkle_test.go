@@ -0,0 +1,152 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestTxnProof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		txns := make([]SignedTxn, n)
+		for i := range txns {
+			txns[i] = testTxn(t, "alice", "bob", uint(i+1), 1, uint64(i))
+		}
+
+		root, err := TxnRoot(txns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := BlockHeader{Number: 1, TxnRoot: root}
+
+		for index, txn := range txns {
+			path, err := merklePath(txns, index)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			txnHash, err := txn.Hash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			authHash, err := txn.AuthHash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			proof := TxnProof{txnHash, authHash, header, path}
+
+			tests := []struct {
+				name   string
+				change func(p *TxnProof)
+				valid  bool
+			}{
+				{"valid", func(p *TxnProof) {}, true},
+				{"other txn", func(p *TxnProof) { p.TxnHash[0] ^= 1 }, false},
+				{"other signature", func(p *TxnProof) { p.AuthHash[0] ^= 1 }, false},
+				{"other root", func(p *TxnProof) { p.Header.TxnRoot[0] ^= 1 }, false},
+				{"sibling on the other side", func(p *TxnProof) {
+					if len(p.Path) > 0 {
+						p.Path[0].Left = !p.Path[0].Left
+					} else {
+						p.Path = []ProofStep{{Hash{}, true}}
+					}
+				}, false},
+				{"sibling dropped", func(p *TxnProof) {
+					if len(p.Path) > 0 {
+						p.Path = p.Path[1:]
+					} else {
+						p.Path = []ProofStep{{Hash{}, false}}
+					}
+				}, false},
+			}
+
+			for _, tt := range tests {
+				p := proof
+				p.Path = append([]ProofStep{}, proof.Path...)
+				tt.change(&p)
+
+				if err := p.Verify(); (err == nil) != tt.valid {
+					t.Errorf("%d txns, txn %d, %s: got error %v, want valid %t", n, index, tt.name, err, tt.valid)
+				}
+			}
+		}
+	}
+}
+
+func TestTxnRoot(t *testing.T) {
+	txns := []SignedTxn{
+		testTxn(t, "alice", "bob", 1, 1, 0),
+		testTxn(t, "alice", "bob", 2, 1, 1),
+		testTxn(t, "alice", "bob", 3, 1, 2),
+	}
+
+	resigned := append([]SignedTxn{}, txns...)
+	resigned[1].Sig = append(Bytes{}, resigned[1].Sig...)
+	resigned[1].Sig[0] ^= 1
+
+	tests := []struct {
+		name string
+		txns []SignedTxn
+		same bool
+	}{
+		{"same txns", txns, true},
+		{"other order", []SignedTxn{txns[1], txns[0], txns[2]}, false},
+		{"txn missing", txns[:2], false},
+		{"other signature", resigned, false},
+		{"no txns", nil, false},
+	}
+
+	root, err := TxnRoot(txns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other, err := TxnRoot(tt.txns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (other == root) != tt.same {
+				t.Errorf("got root %x, want same root %t as %x", other, tt.same, root)
+			}
+
+			block := Block{Header: BlockHeader{TxnRoot: root}, Txns: tt.txns}
+			if err := checkTxnRoot(block); (err == nil) != tt.same {
+				t.Errorf("got error %v, want valid %t", err, tt.same)
+			}
+		})
+	}
+
+	if root, _ := TxnRoot(nil); root != (Hash{}) {
+		t.Errorf("got root %x for a block without txns, want the empty hash", root)
+	}
+}
+
+func TestStateTxnProof(t *testing.T) {
+	s, _ := newTestState(t, nil, "alice")
+	block := produceTestBlock(t, s, "producer",
+		testTxn(t, "alice", "bob", 1, 1, 0),
+		testTxn(t, "alice", "bob", 2, 1, 1),
+	)
+
+	for index, txn := range block.Txns {
+		hash, err := txn.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		proof, err := s.TxnProof(hash)
+		if err != nil {
+			t.Fatalf("txn %d: %s", index, err)
+		}
+		if proof.Header != block.Header {
+			t.Errorf("txn %d: proof has header of block %d, want block %d", index, proof.Header.Number, block.Header.Number)
+		}
+		if err := proof.Verify(); err != nil {
+			t.Errorf("txn %d: %s", index, err)
+		}
+	}
+
+	if _, err := s.TxnProof(Hash{1}); err == nil {
+		t.Errorf("got proof of an unknown txn")
+	}
+}
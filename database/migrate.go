@@ -0,0 +1,140 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// legacyGenesis and legacyBlockFs only hold the fields needed to
+// replay a blockchain whose accounts are names instead of addresses
+type legacyGenesis struct {
+	ChainID   string          `json:"chain_id"`
+	Balances  map[string]uint `json:"balances"`
+	Consensus ConsensusParams `json:"consensus"`
+}
+
+type legacyBlockFs struct {
+	Value struct {
+		Txns []struct {
+			From  string `json:"from"`
+			To    string `json:"to"`
+			Value uint   `json:"value"`
+			Data  string `json:"data"`
+		} `json:"payload"`
+	} `json:"block"`
+}
+
+// MigrateLegacy converts the blockchain in legacyDataDir whose accounts
+// are names into a new genesis in newDataDir. The balances after the last
+// legacy block are allocated in the new genesis to the addresses the names
+// are mapped to. The legacy data dir is left untouched.
+// It returns the hash of the new genesis.
+func MigrateLegacy(legacyDataDir, newDataDir string, accounts map[string]Account) (Hash, error) {
+	content, err := ioutil.ReadFile(getGenesisJsonFilePath(legacyDataDir))
+	if err != nil {
+		return Hash{}, err
+	}
+
+	gen, err := parseGenesis([]byte(genesisJSON))
+	if err != nil {
+		return Hash{}, err
+	}
+
+	// consensus params missing in the legacy genesis keep their defaults
+	legacyGen := legacyGenesis{ChainID: gen.ChainID, Consensus: gen.Consensus}
+	if err := json.Unmarshal(content, &legacyGen); err != nil {
+		return Hash{}, err
+	}
+
+	balances, err := replayLegacyBlocks(legacyGen.Balances, getBlocksDbFilePath(legacyDataDir))
+	if err != nil {
+		return Hash{}, err
+	}
+
+	gen.Time = time.Now().UTC()
+	gen.ChainID = legacyGen.ChainID
+	gen.Consensus = legacyGen.Consensus
+	gen.Balances = make(map[Account]uint)
+
+	unmapped := make([]string, 0)
+	for name, balance := range balances {
+		if balance == 0 {
+			continue
+		}
+
+		// accounts which already are addresses keep their balance
+		account, ok := accounts[name]
+		if !ok && Account(name).Validate() == nil {
+			account, ok = Account(name), true
+		}
+		if !ok {
+			unmapped = append(unmapped, name)
+			continue
+		}
+
+		if err := account.Validate(); err != nil {
+			return Hash{}, err
+		}
+		gen.Balances[account] += balance
+	}
+
+	if len(unmapped) > 0 {
+		sort.Strings(unmapped)
+		return Hash{}, fmt.Errorf("legacy accounts with a balance are not mapped to an address: %s", strings.Join(unmapped, ", "))
+	}
+
+	genesisJson, err := json.MarshalIndent(gen, "", "    ")
+	if err != nil {
+		return Hash{}, err
+	}
+
+	return InitDataDir(newDataDir, genesisJson)
+}
+
+// replayLegacyBlocks returns the balances after applying
+// every legacy block in the db file to the genesis balances
+func replayLegacyBlocks(genesisBalances map[string]uint, path string) (map[string]uint, error) {
+	balances := make(map[string]uint)
+	for name, balance := range genesisBalances {
+		balances[name] = balance
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var blockFs legacyBlockFs
+		if err := json.Unmarshal(scanner.Bytes(), &blockFs); err != nil {
+			return nil, err
+		}
+
+		for _, txn := range blockFs.Value.Txns {
+			if txn.Data == "reward" {
+				balances[txn.To] += txn.Value
+				continue
+			}
+
+			if txn.Value > balances[txn.From] {
+				return nil, fmt.Errorf("legacy txn from %s spends more than its balance", txn.From)
+			}
+			balances[txn.From] -= txn.Value
+			balances[txn.To] += txn.Value
+		}
+	}
+
+	return balances, scanner.Err()
+}
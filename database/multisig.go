@@ -0,0 +1,181 @@
+package database
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// multisigDomain separates multisig accounts from single key accounts
+const multisigDomain = "multisig"
+
+// MultisigAccount is an account controlled by N public keys.
+// Txns from it need valid signatures of Threshold of the keys.
+type MultisigAccount struct {
+	Threshold uint    `json:"threshold"`
+	PubKeys   []Bytes `json:"pub_keys"`
+}
+
+// Signature stores a signature and the public key that made it
+type Signature struct {
+	PubKey Bytes `json:"pub_key"`
+	Sig    Bytes `json:"signature"`
+}
+
+// NewMultisigAccount returns a validated M-of-N multisig account.
+// The keys are sorted so the order they are given in does not matter.
+func NewMultisigAccount(threshold uint, pubKeys []Bytes) (MultisigAccount, error) {
+	sorted := make([]Bytes, len(pubKeys))
+	copy(sorted, pubKeys)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+
+	m := MultisigAccount{threshold, sorted}
+	if err := m.Validate(); err != nil {
+		return MultisigAccount{}, err
+	}
+	return m, nil
+}
+
+// Validate checks that the threshold can be met
+// and the keys are unique, sorted ed25519 keys
+func (m MultisigAccount) Validate() error {
+	if m.Threshold == 0 || m.Threshold > uint(len(m.PubKeys)) {
+		return fmt.Errorf("multisig threshold must be between 1 and %d, not %d", len(m.PubKeys), m.Threshold)
+	}
+
+	for i, pubKey := range m.PubKeys {
+		if len(pubKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("multisig key %x is not an ed25519 public key", []byte(pubKey))
+		}
+		if i > 0 && bytes.Compare(m.PubKeys[i-1], pubKey) >= 0 {
+			return fmt.Errorf("multisig keys must be unique and sorted")
+		}
+	}
+
+	return nil
+}
+
+// Account returns the account controlled by the multisig.
+// It is derived from the threshold and all keys.
+func (m MultisigAccount) Account() Account {
+	h := sha256.New()
+	h.Write([]byte(multisigDomain))
+	threshold := make([]byte, 8)
+	binary.BigEndian.PutUint64(threshold, uint64(m.Threshold))
+	h.Write(threshold)
+	for _, pubKey := range m.PubKeys {
+		h.Write(pubKey)
+	}
+
+	return newAccountFromHash(h.Sum(nil))
+}
+
+// hasKey checks if the key is one of the multisig keys
+func (m MultisigAccount) hasKey(pubKey Bytes) bool {
+	for _, k := range m.PubKeys {
+		if bytes.Equal(k, pubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewMultisigTxn returns an unsigned txn from the multisig account
+// which the owners of the keys sign with AddSig
+func NewMultisigTxn(txn Txn, multisig MultisigAccount) SignedTxn {
+	return SignedTxn{Txn: txn, Multisig: &multisig}
+}
+
+// AddSig adds a partial signature made with the private key to a multisig txn
+func (t *SignedTxn) AddSig(privKey ed25519.PrivateKey) error {
+	if t.Multisig == nil {
+		return fmt.Errorf("txn from %s is not a multisig txn", t.From)
+	}
+
+	pubKey := Bytes(privKey.Public().(ed25519.PublicKey))
+	if !t.Multisig.hasKey(pubKey) {
+		return fmt.Errorf("key %x is not a key of multisig %s", []byte(pubKey), t.From)
+	}
+
+	encoded, err := t.Txn.Encode()
+	if err != nil {
+		return err
+	}
+
+	t.Sigs = mergeSigs(t.Sigs, []Signature{{pubKey, sign(encoded, privKey)}})
+	return nil
+}
+
+// CombineSigs adds the partial signatures of another copy of the same multisig txn
+func (t *SignedTxn) CombineSigs(other SignedTxn) error {
+	if t.Multisig == nil || other.Multisig == nil {
+		return fmt.Errorf("only multisig txns can be combined")
+	}
+
+	if t.Txn != other.Txn || t.Multisig.Account() != other.Multisig.Account() {
+		return fmt.Errorf("txns to combine are not the same txn")
+	}
+
+	t.Sigs = mergeSigs(t.Sigs, other.Sigs)
+	return nil
+}
+
+// mergeSigs returns the signatures of both lists with one signature per key
+func mergeSigs(sigs, others []Signature) []Signature {
+	merged := make([]Signature, 0, len(sigs)+len(others))
+	seen := make(map[string]bool)
+
+	for _, s := range append(append([]Signature{}, sigs...), others...) {
+		if seen[string(s.PubKey)] {
+			continue
+		}
+		seen[string(s.PubKey)] = true
+		merged = append(merged, s)
+	}
+
+	return merged
+}
+
+// isAuthenticMultisig checks that the txn carries at least threshold
+// valid signatures made by distinct keys of the multisig sender
+func (t SignedTxn) isAuthenticMultisig() error {
+	if len(t.PubKey) != 0 || len(t.Sig) != 0 {
+		return fmt.Errorf("multisig txn from %s must not carry a single signature", t.From)
+	}
+
+	if err := t.Multisig.Validate(); err != nil {
+		return err
+	}
+
+	if t.Multisig.Account() != t.From {
+		return fmt.Errorf("multisig does not belong to sender %s", t.From)
+	}
+
+	encoded, err := t.Txn.Encode()
+	if err != nil {
+		return err
+	}
+
+	signers := make(map[string]bool)
+	for _, s := range t.Sigs {
+		if !t.Multisig.hasKey(s.PubKey) {
+			return fmt.Errorf("key %x is not a key of multisig %s", []byte(s.PubKey), t.From)
+		}
+		if signers[string(s.PubKey)] {
+			return fmt.Errorf("key %x signed multisig txn more than once", []byte(s.PubKey))
+		}
+		if !verify(encoded, s.PubKey, s.Sig) {
+			return fmt.Errorf("invalid signature of key %x on multisig txn from %s", []byte(s.PubKey), t.From)
+		}
+		signers[string(s.PubKey)] = true
+	}
+
+	if uint(len(signers)) < t.Multisig.Threshold {
+		return fmt.Errorf("multisig txn from %s has %d of %d required signatures", t.From, len(signers), t.Multisig.Threshold)
+	}
+
+	return nil
+}
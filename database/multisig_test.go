@@ -0,0 +1,184 @@
+package database
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+// testPubKey returns the public key of a test user
+func testPubKey(name string) Bytes {
+	privKey, _ := testKey(name)
+	return Bytes(privKey.Public().(ed25519.PublicKey))
+}
+
+// testMultisig returns the multisig account of the test users
+func testMultisig(t *testing.T, threshold uint, names ...string) MultisigAccount {
+	t.Helper()
+
+	pubKeys := make([]Bytes, len(names))
+	for i, name := range names {
+		pubKeys[i] = testPubKey(name)
+	}
+
+	multisig, err := NewMultisigAccount(threshold, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return multisig
+}
+
+// testMultisigTxn returns a txn of the multisig signed by the signers
+func testMultisigTxn(t *testing.T, multisig MultisigAccount, signers ...string) SignedTxn {
+	t.Helper()
+
+	_, to := testKey("dave")
+	txn := NewMultisigTxn(NewTxn("test", multisig.Account(), to, 10, 1, 0, ""), multisig)
+	for _, signer := range signers {
+		privKey, _ := testKey(signer)
+		if err := txn.AddSig(privKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return txn
+}
+
+func TestNewMultisigAccount(t *testing.T) {
+	alice, bob, carol := testPubKey("alice"), testPubKey("bob"), testPubKey("carol")
+
+	tests := []struct {
+		name      string
+		threshold uint
+		pubKeys   []Bytes
+		valid     bool
+	}{
+		{"2 of 3", 2, []Bytes{alice, bob, carol}, true},
+		{"3 of 3", 3, []Bytes{carol, alice, bob}, true},
+		{"threshold 0", 0, []Bytes{alice, bob}, false},
+		{"threshold above the keys", 3, []Bytes{alice, bob}, false},
+		{"duplicate key", 2, []Bytes{alice, alice}, false},
+		{"not an ed25519 key", 1, []Bytes{alice, Bytes("short")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMultisigAccount(tt.threshold, tt.pubKeys)
+			if (err == nil) != tt.valid {
+				t.Errorf("got error %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+
+	// the order of the keys does not change the account
+	m1 := testMultisig(t, 2, "alice", "bob", "carol")
+	m2 := testMultisig(t, 2, "carol", "bob", "alice")
+	if m1.Account() != m2.Account() {
+		t.Errorf("got accounts %s and %s for the same keys", m1.Account(), m2.Account())
+	}
+	if m3 := testMultisig(t, 3, "alice", "bob", "carol"); m3.Account() == m1.Account() {
+		t.Errorf("got the same account for thresholds 2 and 3")
+	}
+}
+
+func TestMultisigIsAuthentic(t *testing.T) {
+	multisig := testMultisig(t, 2, "alice", "bob", "carol")
+	other := testMultisig(t, 2, "alice", "bob", "dave")
+
+	tests := []struct {
+		name   string
+		txn    func() SignedTxn
+		signed bool
+	}{
+		{"threshold met", func() SignedTxn {
+			return testMultisigTxn(t, multisig, "alice", "bob")
+		}, true},
+		{"all keys", func() SignedTxn {
+			return testMultisigTxn(t, multisig, "carol", "alice", "bob")
+		}, true},
+		{"under threshold", func() SignedTxn {
+			return testMultisigTxn(t, multisig, "alice")
+		}, false},
+		{"no signatures", func() SignedTxn {
+			return testMultisigTxn(t, multisig)
+		}, false},
+		{"duplicate key counted twice", func() SignedTxn {
+			txn := testMultisigTxn(t, multisig, "alice")
+			txn.Sigs = append(txn.Sigs, txn.Sigs[0])
+			return txn
+		}, false},
+		{"signer outside the set", func() SignedTxn {
+			txn := testMultisigTxn(t, multisig, "alice")
+			encoded, err := txn.Txn.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			privKey, _ := testKey("dave")
+			txn.Sigs = append(txn.Sigs, Signature{testPubKey("dave"), sign(encoded, privKey)})
+			return txn
+		}, false},
+		{"invalid signature", func() SignedTxn {
+			txn := testMultisigTxn(t, multisig, "alice", "bob")
+			txn.Sigs[1].Sig = append(Bytes{}, txn.Sigs[1].Sig...)
+			txn.Sigs[1].Sig[0] ^= 1
+			return txn
+		}, false},
+		{"signatures of another txn", func() SignedTxn {
+			txn := testMultisigTxn(t, multisig, "alice", "bob")
+			txn.Value++
+			return txn
+		}, false},
+		{"multisig of another account", func() SignedTxn {
+			txn := testMultisigTxn(t, other, "alice", "bob")
+			txn.From = multisig.Account()
+			return txn
+		}, false},
+		{"single signature as well", func() SignedTxn {
+			txn := testMultisigTxn(t, multisig, "alice", "bob")
+			txn.PubKey = testPubKey("alice")
+			txn.Sig = txn.Sigs[0].Sig
+			return txn
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.txn().IsAuthentic(); (err == nil) != tt.signed {
+				t.Errorf("got error %v, want authentic %t", err, tt.signed)
+			}
+		})
+	}
+}
+
+func TestCombineSigs(t *testing.T) {
+	multisig := testMultisig(t, 2, "alice", "bob", "carol")
+
+	tests := []struct {
+		name   string
+		txn    SignedTxn
+		other  SignedTxn
+		valid  bool
+		sigs   int
+		signed bool
+	}{
+		{"partial signatures", testMultisigTxn(t, multisig, "alice"), testMultisigTxn(t, multisig, "bob"), true, 2, true},
+		{"same signer twice", testMultisigTxn(t, multisig, "alice"), testMultisigTxn(t, multisig, "alice"), true, 1, false},
+		{"overlapping signers", testMultisigTxn(t, multisig, "alice", "bob"), testMultisigTxn(t, multisig, "bob", "carol"), true, 3, true},
+		{"another multisig", testMultisigTxn(t, multisig, "alice"), testMultisigTxn(t, testMultisig(t, 1, "alice", "bob", "carol"), "bob"), false, 1, false},
+		{"not a multisig txn", testMultisigTxn(t, multisig, "alice"), testTxn(t, "bob", "dave", 10, 1, 0), false, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txn := tt.txn
+			err := txn.CombineSigs(tt.other)
+			if (err == nil) != tt.valid {
+				t.Fatalf("got error %v, want valid %t", err, tt.valid)
+			}
+			if len(txn.Sigs) != tt.sigs {
+				t.Errorf("got %d signatures, want %d", len(txn.Sigs), tt.sigs)
+			}
+			if err := txn.IsAuthentic(); (err == nil) != tt.signed {
+				t.Errorf("got error %v, want authentic %t", err, tt.signed)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import "fmt"
+
+// NewCoinbaseTxn returns the txn minting the block reward to the producer.
+// The nonce is the block number so that every coinbase has its own hash.
+func NewCoinbaseTxn(chainID string, producer Account, number uint64, reward uint) SignedTxn {
+	return SignedTxn{Txn: NewTxn(chainID, "", producer, reward, 0, number, "reward")}
+}
+
+// BlockReward returns the amount minted by the coinbase of the block
+// with the given number. The initial reward is halved every halving
+// interval and nothing is minted beyond the maximum supply.
+func BlockReward(number uint64, supply uint, params ConsensusParams) uint {
+	reward := uint(0)
+	if halvings := number / params.HalvingInterval; halvings < 64 {
+		reward = params.BlockReward >> halvings
+	}
+
+	if supply >= params.MaxSupply {
+		return 0
+	}
+	if remaining := params.MaxSupply - supply; reward > remaining {
+		return remaining
+	}
+	return reward
+}
+
+// applyCoinbase mints the block reward if the block has a coinbase.
+// The coinbase must be the first txn of the block, pay the
+// scheduled reward to the producer and no other txn may mint.
+func applyCoinbase(b Block, s *State) error {
+	for i, txn := range b.Txns {
+		if txn.IsReward() && i != 0 {
+			return fmt.Errorf("block %d mints in txn %d, only the first txn can be the coinbase", b.Header.Number, i)
+		}
+	}
+
+	if len(b.Txns) == 0 || !b.Txns[0].IsReward() {
+		return nil
+	}
+
+	coinbase := b.Txns[0]
+	if coinbase.ChainID != s.chainID {
+		return fmt.Errorf("coinbase belongs to chain %q, not %q", coinbase.ChainID, s.chainID)
+	}
+
+	if coinbase.From != "" || len(coinbase.PubKey) != 0 || len(coinbase.Sig) != 0 || coinbase.Multisig != nil || len(coinbase.Sigs) != 0 {
+		return fmt.Errorf("block %d coinbase must not have a sender or signatures", b.Header.Number)
+	}
+
+	if coinbase.To != b.Header.Producer {
+		return fmt.Errorf("block %d rewards %s, not its producer %s", b.Header.Number, coinbase.To, b.Header.Producer)
+	}
+
+	if coinbase.Fee != 0 {
+		return fmt.Errorf("block %d coinbase must not pay a fee", b.Header.Number)
+	}
+
+	if coinbase.Nonce != b.Header.Number {
+		return fmt.Errorf("block %d coinbase has nonce %d, expected the block number", b.Header.Number, coinbase.Nonce)
+	}
+
+	reward := s.engine.Finalize(s, b.Header)
+	if coinbase.Value != reward {
+		return fmt.Errorf("block %d mints %d, the block reward is %d", b.Header.Number, coinbase.Value, reward)
+	}
+
+	s.Balances[coinbase.To] += coinbase.Value
+	s.supply += coinbase.Value
+	return nil
+}
+
+// applyBlockTxns mints the coinbase, completes the other
+// txns of the block and pays their fees to the producer
+func applyBlockTxns(b Block, s *State) error {
+	if err := applyCoinbase(b, s); err != nil {
+		return err
+	}
+
+	txns := b.Txns
+	if len(txns) > 0 && txns[0].IsReward() {
+		txns = txns[1:]
+	}
+	if err := applyTxns(txns, s); err != nil {
+		return err
+	}
+
+	fees := uint(0)
+	for _, txn := range txns {
+		fees += txn.Fee
+	}
+	s.Balances[b.Header.Producer] += fees
+	s.Fees[b.Header.Producer] += fees
+	return nil
+}
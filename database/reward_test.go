@@ -0,0 +1,117 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestBlockReward(t *testing.T) {
+	params := ConsensusParams{BlockReward: 10, HalvingInterval: 100, MaxSupply: 1000}
+
+	tests := []struct {
+		name   string
+		number uint64
+		supply uint
+		reward uint
+	}{
+		{"first block", 0, 0, 10},
+		{"before the first halving", 99, 0, 10},
+		{"first halving", 100, 0, 5},
+		{"before the second halving", 199, 0, 5},
+		{"second halving", 200, 0, 2},
+		{"third halving", 300, 0, 1},
+		{"halved to nothing", 400, 0, 0},
+		{"beyond 64 halvings", 64 * 100, 0, 0},
+		{"last block number", ^uint64(0), 0, 0},
+		{"below max supply", 0, 990, 10},
+		{"capped by max supply", 0, 995, 5},
+		{"at max supply", 0, 1000, 0},
+		{"above max supply", 0, 2000, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reward := BlockReward(tt.number, tt.supply, params); reward != tt.reward {
+				t.Errorf("got reward %d, want %d", reward, tt.reward)
+			}
+		})
+	}
+}
+
+func TestApplyCoinbase(t *testing.T) {
+	s, _ := newTestState(t, nil, "alice")
+	_, producer := testKey("producer")
+	_, bob := testKey("bob")
+
+	pending, err := s.NewPendingBlock(producer, testBlockTime(0), []SignedTxn{testTxn(t, "alice", "bob", 10, 1, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbase, txn := pending.Txns[0], pending.Txns[1]
+
+	tests := []struct {
+		name   string
+		txns   func() []SignedTxn
+		minted uint
+		valid  bool
+	}{
+		{"coinbase first", func() []SignedTxn { return []SignedTxn{coinbase, txn} }, 10, true},
+		{"no coinbase", func() []SignedTxn { return []SignedTxn{txn} }, 0, true},
+		{"coinbase not first", func() []SignedTxn { return []SignedTxn{txn, coinbase} }, 0, false},
+		{"two coinbases", func() []SignedTxn { return []SignedTxn{coinbase, coinbase} }, 0, false},
+		{"pays someone other than the producer", func() []SignedTxn {
+			c := coinbase
+			c.To = bob
+			return []SignedTxn{c}
+		}, 0, false},
+		{"mints more than the reward", func() []SignedTxn {
+			c := coinbase
+			c.Value++
+			return []SignedTxn{c}
+		}, 0, false},
+		{"mints less than the reward", func() []SignedTxn {
+			c := coinbase
+			c.Value--
+			return []SignedTxn{c}
+		}, 0, false},
+		{"other nonce", func() []SignedTxn {
+			c := coinbase
+			c.Nonce++
+			return []SignedTxn{c}
+		}, 0, false},
+		{"fee", func() []SignedTxn {
+			c := coinbase
+			c.Fee = 1
+			return []SignedTxn{c}
+		}, 0, false},
+		{"sender", func() []SignedTxn {
+			c := coinbase
+			c.From = bob
+			return []SignedTxn{c}
+		}, 0, false},
+		{"other chain", func() []SignedTxn {
+			c := coinbase
+			c.ChainID = "other"
+			return []SignedTxn{c}
+		}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pendingState := s.copy()
+			err := applyCoinbase(Block{Header: pending.Header, Txns: tt.txns()}, &pendingState)
+			if (err == nil) != tt.valid {
+				t.Fatalf("got error %v, want valid %t", err, tt.valid)
+			}
+			if !tt.valid {
+				return
+			}
+
+			if minted := pendingState.Supply() - s.Supply(); minted != tt.minted {
+				t.Errorf("minted %d, want %d", minted, tt.minted)
+			}
+			if balance := pendingState.Balances[producer]; balance != tt.minted {
+				t.Errorf("producer balance is %d, want %d", balance, tt.minted)
+			}
+		})
+	}
+}
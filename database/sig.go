@@ -0,0 +1,43 @@
+package database
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Bytes is a byte slice that is hex encoded in json
+type Bytes []byte
+
+func (b Bytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+func (b *Bytes) UnmarshalText(data []byte) error {
+	decoded, err := hex.DecodeString(string(data))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// NewAccountFromPubKey returns the account owned by the given public key.
+// The account is the address of the first 20 bytes of the sha256 hash of the key.
+func NewAccountFromPubKey(pubKey ed25519.PublicKey) Account {
+	hash := sha256.Sum256(pubKey)
+	return newAccountFromHash(hash[:])
+}
+
+// sign signs the given message with the private key
+func sign(msg []byte, privKey ed25519.PrivateKey) Bytes {
+	return ed25519.Sign(privKey, msg)
+}
+
+// verify checks that sig is a valid signature of msg made by pubKey
+func verify(msg []byte, pubKey, sig Bytes) bool {
+	if len(pubKey) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(pubKey), msg, sig)
+}
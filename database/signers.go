@@ -0,0 +1,129 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+)
+
+// voteAddData and voteRemoveData mark a txn from a signer voting
+// to authorize the account the txn is sent to or to revoke it
+const (
+	voteAddData    = "vote:add"
+	voteRemoveData = "vote:remove"
+)
+
+// signerVote is the vote of a signer to authorize or revoke a candidate
+type signerVote struct {
+	Signer    Account
+	Candidate Account
+	Add       bool
+}
+
+// NewVoteTxn returns a txn from a signer voting to authorize
+// the candidate as a signer or to revoke it when add is false
+func NewVoteTxn(chainID string, signer, candidate Account, add bool, fee uint, nonce uint64) Txn {
+	data := voteRemoveData
+	if add {
+		data = voteAddData
+	}
+	return NewTxn(chainID, signer, candidate, 0, fee, nonce, data)
+}
+
+// IsVote checks if the txn is a vote on a signer
+func (t Txn) IsVote() bool {
+	return t.Data == voteAddData || t.Data == voteRemoveData
+}
+
+// Signers returns the signers authorized to produce blocks in
+// turns when the chain uses proof of authority, sorted in the
+// order they take turns
+func (s *State) Signers() []Account {
+	return append([]Account{}, s.signers...)
+}
+
+// sortSigners returns the signers in the order they take turns
+func sortSigners(signers []Account) []Account {
+	sorted := append([]Account{}, signers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// isSigner checks if the account is an authorized signer
+func (s *State) isSigner(account Account) bool {
+	for _, signer := range s.signers {
+		if signer == account {
+			return true
+		}
+	}
+	return false
+}
+
+// applyVote records the vote of a signer. The candidate is authorized
+// or revoked once more than half of the signers voted for it.
+func applyVote(txn SignedTxn, s *State) error {
+	if len(s.signers) == 0 {
+		return fmt.Errorf("signer votes are only allowed on chains with signers")
+	}
+
+	if !s.isSigner(txn.From) {
+		return fmt.Errorf("%s cannot vote, it is not an authorized signer", txn.From)
+	}
+
+	if txn.Value != 0 {
+		return fmt.Errorf("a signer vote cannot transfer value")
+	}
+
+	add := txn.Data == voteAddData
+	if add == s.isSigner(txn.To) {
+		if add {
+			return fmt.Errorf("%s is already an authorized signer", txn.To)
+		}
+		return fmt.Errorf("%s is not an authorized signer", txn.To)
+	}
+
+	// a new vote replaces the previous vote of the signer on the candidate,
+	// the votes are copied so that states sharing them are not changed
+	votes := make([]signerVote, 0, len(s.votes)+1)
+	for _, vote := range s.votes {
+		if vote.Signer != txn.From || vote.Candidate != txn.To {
+			votes = append(votes, vote)
+		}
+	}
+	votes = append(votes, signerVote{txn.From, txn.To, add})
+
+	tally := 0
+	for _, vote := range votes {
+		if vote.Candidate == txn.To && vote.Add == add {
+			tally++
+		}
+	}
+
+	if tally <= len(s.signers)/2 {
+		s.votes = votes
+		return nil
+	}
+
+	signers := make([]Account, 0, len(s.signers)+1)
+	for _, signer := range s.signers {
+		if signer != txn.To {
+			signers = append(signers, signer)
+		}
+	}
+	if add {
+		signers = append(signers, txn.To)
+	}
+
+	if len(signers) == 0 {
+		return fmt.Errorf("the last authorized signer cannot be revoked")
+	}
+
+	// votes on the candidate are settled and a revoked signer's votes are dropped
+	s.votes = make([]signerVote, 0, len(votes))
+	for _, vote := range votes {
+		if vote.Candidate != txn.To && vote.Signer != txn.To {
+			s.votes = append(s.votes, vote)
+		}
+	}
+	s.signers = sortSigners(signers)
+	return nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyVote(t *testing.T) {
+	_, alice := testKey("alice")
+	_, bob := testKey("bob")
+	_, carol := testKey("carol")
+	_, dave := testKey("dave")
+	_, erin := testKey("erin")
+
+	// vote is a vote of the signer on the candidate
+	type vote struct {
+		signer    Account
+		candidate Account
+		add       bool
+	}
+
+	tests := []struct {
+		name    string
+		signers []Account
+		votes   []vote
+		valid   bool
+		want    []Account
+	}{
+		{"majority adds a signer", []Account{alice, bob, carol}, []vote{{alice, dave, true}, {bob, dave, true}}, true, []Account{alice, bob, carol, dave}},
+		{"vote without a majority does nothing", []Account{alice, bob, carol}, []vote{{alice, dave, true}}, true, []Account{alice, bob, carol}},
+		{"half is not a majority", []Account{alice, bob, carol, dave}, []vote{{alice, erin, true}, {bob, erin, true}}, true, []Account{alice, bob, carol, dave}},
+		{"repeated vote counts once", []Account{alice, bob, carol}, []vote{{alice, dave, true}, {alice, dave, true}}, true, []Account{alice, bob, carol}},
+		{"majority removes a signer", []Account{alice, bob, carol}, []vote{{alice, carol, false}, {bob, carol, false}}, true, []Account{alice, bob}},
+		{"single signer adds another", []Account{alice}, []vote{{alice, bob, true}}, true, []Account{alice, bob}},
+		{"last signer cannot be removed", []Account{alice}, []vote{{alice, alice, false}}, false, []Account{alice}},
+		{"outsider cannot vote", []Account{alice, bob, carol}, []vote{{dave, erin, true}}, false, []Account{alice, bob, carol}},
+		{"signer cannot be added twice", []Account{alice, bob}, []vote{{alice, bob, true}}, false, []Account{alice, bob}},
+		{"outsider cannot be removed", []Account{alice, bob}, []vote{{alice, dave, false}}, false, []Account{alice, bob}},
+		{"no signers", nil, []vote{{alice, bob, true}}, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &State{signers: sortSigners(tt.signers), votes: make([]signerVote, 0)}
+
+			var err error
+			for _, v := range tt.votes {
+				if err = applyVote(SignedTxn{Txn: NewVoteTxn("test", v.signer, v.candidate, v.add, 1, 0)}, s); err != nil {
+					break
+				}
+			}
+			if (err == nil) != tt.valid {
+				t.Errorf("got error %v, want valid %t", err, tt.valid)
+			}
+
+			if want := sortSigners(tt.want); !reflect.DeepEqual(s.signers, want) && len(s.signers)+len(want) > 0 {
+				t.Errorf("got signers %v, want %v", s.signers, want)
+			}
+		})
+	}
+
+	// the votes of a revoked signer are dropped with it
+	s := &State{signers: sortSigners([]Account{alice, bob, carol}), votes: make([]signerVote, 0)}
+	for _, v := range []vote{{carol, dave, true}, {alice, carol, false}, {bob, carol, false}} {
+		if err := applyVote(SignedTxn{Txn: NewVoteTxn("test", v.signer, v.candidate, v.add, 1, 0)}, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.votes) != 0 {
+		t.Errorf("got votes %v after carol was revoked, want none", s.votes)
+	}
+
+	// a vote cannot transfer value
+	txn := SignedTxn{Txn: NewVoteTxn("test", alice, dave, true, 1, 0)}
+	txn.Value = 1
+	if err := applyVote(txn, &State{signers: []Account{alice}}); err == nil {
+		t.Errorf("got no error for a vote transferring value")
+	}
+}
@@ -6,19 +6,37 @@ import (
 	"fmt"
 	"os"
 	"reflect"
-	"time"
 )
 
 // State stores the current state of blockchain
-// It stores the balances of all individuals,
-// a list of all transactions and a pointer to dbFile
+// It stores the balances and next nonces of all individuals,
+// the fees earned by block producers,
+// the txns waiting for a block and a pointer to dbFile
 type State struct {
 	Balances        map[Account]uint
-	txnMempool      []Txn
+	Nonces          map[Account]uint64
+	Fees            map[Account]uint
+	txnMempool      *mempool
+	mempoolLimits   MempoolLimits
+	txnFates        *txnFates
 	dbFile          *os.File
 	latestBlock     Block
 	latestBlockHash Hash
 	hasGenesisBlock bool
+	txnIndex        map[Hash]txnRecord
+	chainID         string
+	genesisHash     Hash
+	consensus       ConsensusParams
+	supply          uint
+	blocks          map[Hash]*blockNode
+	children        map[Hash][]Hash
+	canonical       []Hash
+	genesisTime     uint64
+	signers         []Account
+	votes           []signerVote
+	engine          Engine
+	checkpoints     map[uint64]Hash
+	maxReorgDepth   uint64
 }
 
 func NewStateFromDisk(path string) (*State, error) {
@@ -34,10 +52,22 @@ func NewStateFromDisk(path string) (*State, error) {
 		return nil, err
 	}
 
+	genesisHash, err := gen.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	engine, err := newEngine(gen.Consensus)
+	if err != nil {
+		return nil, err
+	}
+
 	// update balances
 	balances := make(map[Account]uint)
+	supply := uint(0)
 	for account, balance := range gen.Balances {
 		balances[account] = balance
+		supply += balance
 	}
 
 	f, err := os.OpenFile(getBlocksDbFilePath(path), os.O_APPEND|os.O_RDWR|os.O_CREATE, 0600)
@@ -45,15 +75,36 @@ func NewStateFromDisk(path string) (*State, error) {
 		return nil, err
 	}
 
+	// a line holds a whole block which can be larger than the default buffer
 	scanner := bufio.NewScanner(f)
-	state := &State{balances, make([]Txn, 0), f, Block{}, Hash{}, false}
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxBlockFsSize)
+	// the first block is a child of the genesis
+	state := &State{
+		Balances:        balances,
+		Nonces:          make(map[Account]uint64),
+		Fees:            make(map[Account]uint),
+		txnMempool:      newMempool(),
+		mempoolLimits:   DefaultMempoolLimits,
+		txnFates:        newTxnFates(),
+		dbFile:          f,
+		latestBlockHash: genesisHash,
+		txnIndex:        make(map[Hash]txnRecord),
+		chainID:         gen.ChainID,
+		genesisHash:     genesisHash,
+		consensus:       gen.Consensus,
+		supply:          supply,
+		blocks:          make(map[Hash]*blockNode),
+		children:        make(map[Hash][]Hash),
+		canonical:       make([]Hash, 0),
+		genesisTime:     uint64(gen.Time.Unix()),
+		signers:         sortSigners(gen.Consensus.Signers),
+		votes:           make([]signerVote, 0),
+		engine:          engine,
+		checkpoints:     make(map[uint64]Hash),
+	}
 
 	// iterate over the txns
 	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return nil, err
-		}
-
 		blockFsJson := scanner.Bytes()
 		if len(blockFsJson) == 0 {
 			break
@@ -64,14 +115,17 @@ func NewStateFromDisk(path string) (*State, error) {
 			return nil, err
 		}
 
-		err = applyTxns(blockFs.Value.Txns, state)
+		// side branches are replayed in the order they were received
+		// so that the same branch becomes the canonical chain again
+		_, err = state.addBlock(blockFs.Value, false)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		state.latestBlock = blockFs.Value
-		state.latestBlockHash = blockFs.Key
-		state.hasGenesisBlock = true
+	// a line longer than the buffer stops the scan with an error
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the blocks of %s: %s", getBlocksDbFilePath(path), err)
 	}
 
 	return state, nil
@@ -88,58 +142,56 @@ func (s *State) AddBlocks(blocks []Block) error {
 	return nil
 }
 
-// Add adds a block to the current state
+// Add adds a block to the current state. A block of a side branch is kept
+// and the branch becomes the canonical chain once it has the most work.
+// Adding a known block does nothing.
 func (s *State) AddBlock(b Block) (Hash, error) {
-	pendingState := s.copy()
-
-	err := applyBlock(b, pendingState)
-	if err != nil {
-		return Hash{}, err
-	}
-
-	blockHash, err := b.Hash()
-	if err != nil {
-		return Hash{}, err
-	}
+	return s.addBlock(b, true)
+}
 
+// writeBlock appends the block to the db file
+func (s *State) writeBlock(blockHash Hash, b Block) error {
 	blockFs := BlockFs{blockHash, b}
 	blockFsJson, err := json.Marshal(blockFs)
 	if err != nil {
-		return Hash{}, err
+		return err
 	}
 
 	_, err = s.dbFile.Write(append(blockFsJson, '\n'))
-	if err != nil {
-		return Hash{}, err
-	}
-
-	s.Balances = pendingState.Balances
-	s.latestBlockHash = blockHash
-	s.latestBlock = b
-	s.hasGenesisBlock = true
-
-	return blockHash, nil
+	return err
 }
 
-// applyBlock adds all the txns in the block to the state
-func applyBlock(b Block, s State) error {
-	nextExpectedBlockNumber := s.latestBlock.Header.Number + 1
+// applyBlock adds all the txns in the block to the state.
+// The header was checked by checkBlockHeader before.
+func applyBlock(b Block, s *State) error {
+	nextExpectedBlockNumber := s.NextBlockNumber()
 
 	// validate that the next block number increases by 1
-	if s.hasGenesisBlock && b.Header.Number != nextExpectedBlockNumber {
+	if b.Header.Number != nextExpectedBlockNumber {
 		return fmt.Errorf("next expected block must be %d, not %d", nextExpectedBlockNumber, b.Header.Number)
 	}
 
-	// validate the incoming block parent hash equals the current hash
-	if s.hasGenesisBlock && s.latestBlock.Header.Number > 0 && !reflect.DeepEqual(b.Header.Parent, s.latestBlockHash) {
+	// validate the incoming block parent hash equals the current hash,
+	// the parent of the first block is the genesis hash
+	if !reflect.DeepEqual(b.Header.Parent, s.latestBlockHash) {
 		return fmt.Errorf("next block parent hash must be %x not %x", s.latestBlockHash, b.Header.Parent)
 	}
 
-	return applyTxns(b.Txns, &s)
+	// validate the consensus fields of the header with the engine
+	if err := s.engine.VerifyHeader(s, b.Header); err != nil {
+		return err
+	}
+
+	if err := applyBlockTxns(b, s); err != nil {
+		return err
+	}
+
+	// validate that the header commits to the resulting state
+	return checkStateRoot(b, s)
 }
 
 // applyTxns completes the given transactions on the state
-func applyTxns(txns []Txn, s *State) error {
+func applyTxns(txns []SignedTxn, s *State) error {
 	for _, txn := range txns {
 		err := applyTxn(txn, s)
 		if err != nil {
@@ -150,25 +202,55 @@ func applyTxns(txns []Txn, s *State) error {
 }
 
 // applyTxn completes the given transaction on the state
-func applyTxn(txn Txn, s *State) error {
-	// check is txn is block reward
+func applyTxn(txn SignedTxn, s *State) error {
+	// check that the txn belongs to this chain
+	if txn.ChainID != s.chainID {
+		return fmt.Errorf("txn belongs to chain %q, not %q", txn.ChainID, s.chainID)
+	}
+
+	// check that funds are sent to a valid account
+	if err := txn.To.Validate(); err != nil {
+		return err
+	}
+
+	// only the coinbase of a block can mint
 	if txn.IsReward() {
-		s.Balances[txn.To] += txn.Value
-		return nil
+		return fmt.Errorf("reward txns can only be the coinbase of a block")
 	}
 
-	// check if account has enough funds
-	if txn.Value > s.Balances[txn.From] {
+	// check that the txn was signed by the sender
+	if err := txn.IsAuthentic(); err != nil {
+		return err
+	}
+
+	// check that the txn is the next txn of the sender
+	// so that the same txn cannot be applied twice
+	expectedNonce := s.Nonces[txn.From]
+	if txn.Nonce != expectedNonce {
+		return fmt.Errorf("wrong nonce %d for %s, expected %d", txn.Nonce, txn.From, expectedNonce)
+	}
+
+	// check if account has enough funds for the value and the fee
+	if txn.Value > txn.Cost() || txn.Cost() > s.Balances[txn.From] {
 		return fmt.Errorf("insufficient funds")
 	}
 
-	// complete txn
-	s.Balances[txn.From] -= txn.Value
+	// a vote on a signer is recorded before any funds move
+	if txn.IsVote() {
+		if err := applyVote(txn, s); err != nil {
+			return err
+		}
+	}
+
+	// complete txn, the fee is paid to the producer with the block
+	s.Balances[txn.From] -= txn.Cost()
 	s.Balances[txn.To] += txn.Value
+	s.Nonces[txn.From]++
 	return nil
 }
 
-// Latest Snapshot returns the latest snapshot of the current state
+// Latest Snapshot returns the latest snapshot of the current state.
+// It is the genesis hash until the first block is added.
 func (s *State) LatestBlockHash() Hash {
 	return s.latestBlockHash
 }
@@ -176,6 +258,36 @@ func (s *State) LatestBlock() Block {
 	return s.latestBlock
 }
 
+// ChainID returns the id of the chain loaded from genesis
+func (s *State) ChainID() string {
+	return s.chainID
+}
+
+// Consensus returns the consensus params loaded from genesis
+func (s *State) Consensus() ConsensusParams {
+	return s.consensus
+}
+
+// Engine returns the consensus engine picked by genesis
+func (s *State) Engine() Engine {
+	return s.engine
+}
+
+// GenesisTime returns the unix time of the genesis
+func (s *State) GenesisTime() uint64 {
+	return s.genesisTime
+}
+
+// GenesisHash returns the hash of the genesis the chain is built on
+func (s *State) GenesisHash() Hash {
+	return s.genesisHash
+}
+
+// NextNonce returns the nonce the next txn of the account must have
+func (s *State) NextNonce(account Account) uint64 {
+	return s.Nonces[account]
+}
+
 // Close closes the db file
 func (s *State) Close() error {
 	return s.dbFile.Close()
@@ -190,57 +302,83 @@ func (s *State) NextBlockNumber() uint64 {
 	return s.LatestBlock().Header.Number + 1
 }
 
-// copy deep copies the current state
+// copy deep copies the current state without its mempool
 func (s *State) copy() State {
 	c := State{}
 	c.hasGenesisBlock = s.hasGenesisBlock
+	c.chainID = s.chainID
+	c.genesisHash = s.genesisHash
+	c.consensus = s.consensus
+	c.supply = s.supply
+	c.genesisTime = s.genesisTime
+	// signers and votes are never changed in place
+	c.signers = s.signers
+	c.votes = s.votes
+	c.engine = s.engine
+	c.checkpoints = s.checkpoints
+	c.maxReorgDepth = s.maxReorgDepth
 	c.latestBlock = s.latestBlock
 	c.latestBlockHash = s.latestBlockHash
-	c.txnMempool = make([]Txn, len(s.txnMempool))
+	// the block tree is shared, only the canonical chain differs
+	c.blocks = s.blocks
+	c.children = s.children
+	c.canonical = s.canonical
 	c.Balances = make(map[Account]uint)
+	c.Nonces = make(map[Account]uint64)
+	c.Fees = make(map[Account]uint)
 
 	for acc, balance := range s.Balances {
 		c.Balances[acc] = balance
 	}
 
-	c.txnMempool = append(c.txnMempool, s.txnMempool...)
+	for acc, nonce := range s.Nonces {
+		c.Nonces[acc] = nonce
+	}
+
+	for acc, fees := range s.Fees {
+		c.Fees[acc] = fees
+	}
 
 	return c
 }
 
-// Persist adds the transactions to the block
-func (s *State) Persist() (Hash, error) {
-	latestBlockHash, err := s.latestBlock.Hash()
-	if err != nil {
-		return Hash{}, err
-	}
-	block := NewBlock(
-		latestBlockHash,
-		s.latestBlock.Header.Number+1,
-		uint64(time.Now().Unix()),
-		s.txnMempool,
-	)
-	blockHash, err := block.Hash()
-	if err != nil {
-		return Hash{}, err
+// Supply returns the amount of paisa in existence
+func (s *State) Supply() uint {
+	return s.supply
+}
+
+// NewPendingBlock returns an unsealed block with the given txns
+// on top of the latest block, its header prepared by the engine.
+// The block reward of the producer is minted by a coinbase in
+// front of the txns. It fails if the txns can not be applied.
+func (s *State) NewPendingBlock(producer Account, now uint64, txns []SignedTxn) (Block, error) {
+	header := BlockHeader{
+		ChainID:  s.chainID,
+		Parent:   s.latestBlockHash,
+		Number:   s.NextBlockNumber(),
+		Producer: producer,
+	}
+	if err := s.engine.PrepareHeader(s, &header, now); err != nil {
+		return Block{}, err
 	}
 
-	blockFs := BlockFs{blockHash, block}
+	if reward := s.engine.Finalize(s, header); reward > 0 {
+		coinbase := NewCoinbaseTxn(s.chainID, producer, header.Number, reward)
+		txns = append([]SignedTxn{coinbase}, txns...)
+	}
 
-	blockFsJson, err := json.Marshal(blockFs)
+	txnRoot, err := TxnRoot(txns)
 	if err != nil {
-		return Hash{}, err
+		return Block{}, err
 	}
+	header.TxnRoot = txnRoot
 
-	fmt.Printf("Persisting new block to disk:\n%s\n", blockFsJson)
-
-	if _, err = s.dbFile.Write(append(blockFsJson, '\n')); err != nil {
-		return Hash{}, err
+	// the state root is the state after applying the block
+	pendingState := s.copy()
+	if err := applyBlockTxns(Block{Header: header, Txns: txns}, &pendingState); err != nil {
+		return Block{}, err
 	}
+	header.StateRoot = pendingState.StateRoot()
 
-	s.latestBlockHash = latestBlockHash
-	s.latestBlock = block
-	s.txnMempool = []Txn{}
-
-	return latestBlockHash, nil
+	return Block{Header: header, Txns: txns}, nil
 }
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// The state root is the root of a sparse merkle tree over the accounts.
+// Each account has a leaf at the path given by the bits of the hash of
+// its address, holding its balance and next nonce. A subtree without
+// accounts hashes to the empty hash and a subtree with a single account
+// to the leaf of that account, so the tree only grows as deep as needed
+// to tell the accounts apart.
+
+// stateLeaf is an account in the sparse merkle tree
+type stateLeaf struct {
+	key   Hash
+	value Hash
+}
+
+// ProofLeaf is the leaf of another account found on the path of an
+// account which is not part of the state
+type ProofLeaf struct {
+	Key   Hash `json:"key"`
+	Value Hash `json:"value"`
+}
+
+// BalanceProof proves the balance and next nonce of an account in the
+// state after the block with the given header. Siblings are ordered from
+// the leaf up to the root. Accounts without balance and nonce are not part
+// of the state, for them Leaf is the other account found at the end of the
+// path or nil if the path ends in an empty subtree.
+type BalanceProof struct {
+	Account  Account     `json:"account"`
+	Balance  uint        `json:"balance"`
+	Nonce    uint64      `json:"next_nonce"`
+	Header   BlockHeader `json:"header"`
+	Leaf     *ProofLeaf  `json:"leaf,omitempty"`
+	Siblings []Hash      `json:"siblings"`
+}
+
+// stateKey returns the path of an account in the sparse merkle tree
+func stateKey(account Account) Hash {
+	return sha256.Sum256([]byte(account))
+}
+
+// stateValue returns the hash of the balance and next nonce of an account
+func stateValue(balance uint, nonce uint64) Hash {
+	data := make([]byte, 16)
+	binary.BigEndian.PutUint64(data[:8], uint64(balance))
+	binary.BigEndian.PutUint64(data[8:], nonce)
+	return sha256.Sum256(data)
+}
+
+// keyBit returns the bit of the key at the depth, 0 for the left subtree
+func keyBit(key Hash, depth int) byte {
+	return key[depth/8] >> (7 - depth%8) & 1
+}
+
+// stateLeaves returns the leaves of the accounts with a balance
+// or a nonce, sorted by their keys
+func (s *State) stateLeaves() []stateLeaf {
+	leaves := make([]stateLeaf, 0, len(s.Balances))
+	seen := make(map[Account]struct{}, len(s.Balances))
+	add := func(account Account) {
+		if _, ok := seen[account]; ok {
+			return
+		}
+		seen[account] = struct{}{}
+
+		if s.Balances[account] == 0 && s.Nonces[account] == 0 {
+			return
+		}
+		leaves = append(leaves, stateLeaf{stateKey(account), stateValue(s.Balances[account], s.Nonces[account])})
+	}
+
+	for account := range s.Balances {
+		add(account)
+	}
+	for account := range s.Nonces {
+		add(account)
+	}
+
+	sort.Slice(leaves, func(i, j int) bool {
+		return bytes.Compare(leaves[i].key[:], leaves[j].key[:]) < 0
+	})
+	return leaves
+}
+
+// splitLeaves returns the number of sorted leaves in the left subtree at the depth
+func splitLeaves(leaves []stateLeaf, depth int) int {
+	return sort.Search(len(leaves), func(i int) bool {
+		return keyBit(leaves[i].key, depth) == 1
+	})
+}
+
+// subtreeRoot returns the root of the subtree of the sorted leaves at the depth
+func subtreeRoot(leaves []stateLeaf, depth int) Hash {
+	switch len(leaves) {
+	case 0:
+		return Hash{}
+	case 1:
+		return merkleLeaf(leaves[0].key, leaves[0].value)
+	}
+
+	split := splitLeaves(leaves, depth)
+	return merkleNode(subtreeRoot(leaves[:split], depth+1), subtreeRoot(leaves[split:], depth+1))
+}
+
+// StateRoot returns the root of the sparse merkle tree over the accounts
+func (s *State) StateRoot() Hash {
+	return subtreeRoot(s.stateLeaves(), 0)
+}
+
+// BalanceProof returns the balance of the account in the latest
+// state with the proof that the latest block commits to it
+func (s *State) BalanceProof(account Account) (BalanceProof, error) {
+	if !s.hasGenesisBlock {
+		return BalanceProof{}, fmt.Errorf("no block commits to the state yet")
+	}
+
+	key := stateKey(account)
+	proof := BalanceProof{
+		Account: account,
+		Balance: s.Balances[account],
+		Nonce:   s.Nonces[account],
+		Header:  s.latestBlock.Header,
+	}
+
+	// walk down the path of the account until its subtree
+	// has at most one leaf, collecting the other subtrees
+	leaves := s.stateLeaves()
+	siblings := []Hash{}
+	for depth := 0; len(leaves) > 1; depth++ {
+		split := splitLeaves(leaves, depth)
+		if keyBit(key, depth) == 0 {
+			siblings = append(siblings, subtreeRoot(leaves[split:], depth+1))
+			leaves = leaves[:split]
+		} else {
+			siblings = append(siblings, subtreeRoot(leaves[:split], depth+1))
+			leaves = leaves[split:]
+		}
+	}
+
+	if len(leaves) == 1 && leaves[0].key != key {
+		proof.Leaf = &ProofLeaf{leaves[0].key, leaves[0].value}
+	}
+
+	// the siblings are verified from the leaf up
+	proof.Siblings = make([]Hash, len(siblings))
+	for i, sibling := range siblings {
+		proof.Siblings[len(siblings)-1-i] = sibling
+	}
+	return proof, nil
+}
+
+// Verify checks that the balance and nonce of the account
+// are committed to by the state root of the header
+func (p BalanceProof) Verify() error {
+	key := stateKey(p.Account)
+	depth := len(p.Siblings)
+	if depth > len(key)*8 {
+		return fmt.Errorf("proof of %d siblings is deeper than the tree", depth)
+	}
+
+	var node Hash
+	switch {
+	case p.Balance != 0 || p.Nonce != 0:
+		if p.Leaf != nil {
+			return fmt.Errorf("account %s with a balance must not be proven by another leaf", p.Account)
+		}
+		node = merkleLeaf(key, stateValue(p.Balance, p.Nonce))
+	case p.Leaf != nil:
+		if p.Leaf.Key == key {
+			return fmt.Errorf("account %s without a balance must not be proven by its own leaf", p.Account)
+		}
+		// the other leaf must be on the path of the account
+		for i := 0; i < depth; i++ {
+			if keyBit(p.Leaf.Key, i) != keyBit(key, i) {
+				return fmt.Errorf("leaf %x is not on the path of account %s", p.Leaf.Key, p.Account)
+			}
+		}
+		node = merkleLeaf(p.Leaf.Key, p.Leaf.Value)
+	}
+
+	for i, sibling := range p.Siblings {
+		if keyBit(key, depth-1-i) == 0 {
+			node = merkleNode(node, sibling)
+		} else {
+			node = merkleNode(sibling, node)
+		}
+	}
+
+	if node != p.Header.StateRoot {
+		return fmt.Errorf("balance %d and next nonce %d of account %s do not match the state root %x of block %d", p.Balance, p.Nonce, p.Account, p.Header.StateRoot, p.Header.Number)
+	}
+	return nil
+}
+
+// checkStateRoot validates that the header of the block
+// commits to the state after applying the block
+func checkStateRoot(b Block, s *State) error {
+	if root := s.StateRoot(); root != b.Header.StateRoot {
+		return fmt.Errorf("block %d has state root %x, the state after the block has root %x", b.Header.Number, b.Header.StateRoot, root)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStateRoot(t *testing.T) {
+	_, alice := testKey("alice")
+	_, bob := testKey("bob")
+
+	tests := []struct {
+		name     string
+		balances map[Account]uint
+		nonces   map[Account]uint64
+		root     Hash
+	}{
+		{"empty", nil, nil, Hash{}},
+		{"single account", map[Account]uint{alice: 5}, nil, merkleLeaf(stateKey(alice), stateValue(5, 0))},
+		{"nonce only", nil, map[Account]uint64{alice: 1}, merkleLeaf(stateKey(alice), stateValue(0, 1))},
+		{"empty accounts are left out", map[Account]uint{alice: 5, bob: 0}, map[Account]uint64{bob: 0}, merkleLeaf(stateKey(alice), stateValue(5, 0))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &State{Balances: tt.balances, Nonces: tt.nonces}
+			if root := s.StateRoot(); root != tt.root {
+				t.Errorf("got root %x, want %x", root, tt.root)
+			}
+		})
+	}
+
+	// two accounts are told apart by the first bit in which their keys differ
+	s := &State{Balances: map[Account]uint{alice: 5, bob: 7}}
+	left, right := merkleLeaf(stateKey(alice), stateValue(5, 0)), merkleLeaf(stateKey(bob), stateValue(7, 0))
+	depth := 0
+	for keyBit(stateKey(alice), depth) == keyBit(stateKey(bob), depth) {
+		depth++
+	}
+	if keyBit(stateKey(alice), depth) == 1 {
+		left, right = right, left
+	}
+	root := merkleNode(left, right)
+	for i := 0; i < depth; i++ {
+		if keyBit(stateKey(alice), depth-1-i) == 0 {
+			root = merkleNode(root, Hash{})
+		} else {
+			root = merkleNode(Hash{}, root)
+		}
+	}
+	if got := s.StateRoot(); got != root {
+		t.Errorf("got root %x for two accounts, want %x", got, root)
+	}
+}
+
+// balanceProofCase changes a valid balance proof
+type balanceProofCase struct {
+	name   string
+	change func(p *BalanceProof)
+	valid  bool
+}
+
+func TestBalanceProof(t *testing.T) {
+	funded := []string{"alice", "dave", "erin", "frank", "grace", "heidi"}
+	s, _ := newTestState(t, nil, funded...)
+
+	if _, err := s.BalanceProof(Account("any")); err == nil {
+		t.Fatalf("got a proof before any block commits to the state")
+	}
+	produceTestBlock(t, s, "producer", testTxn(t, "alice", "bob", 100, 1, 0))
+
+	names := append([]string{"bob", "producer"}, funded...)
+	for i := 0; i < 20; i++ {
+		names = append(names, fmt.Sprintf("absent-%d", i))
+	}
+
+	absentLeaves, absentEmpty := 0, 0
+	for _, name := range names {
+		_, account := testKey(name)
+		proof, err := s.BalanceProof(account)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		present := proof.Balance != 0 || proof.Nonce != 0
+		if !present && proof.Leaf != nil {
+			absentLeaves++
+		} else if !present {
+			absentEmpty++
+		}
+
+		tests := []balanceProofCase{
+			{"valid", func(p *BalanceProof) {}, true},
+			{"higher balance", func(p *BalanceProof) { p.Balance++ }, false},
+			{"other nonce", func(p *BalanceProof) { p.Nonce++ }, false},
+			{"other root", func(p *BalanceProof) { p.Header.StateRoot[0] ^= 1 }, false},
+			{"sibling added", func(p *BalanceProof) { p.Siblings = append(p.Siblings, Hash{}) }, false},
+			{"own leaf as other leaf", func(p *BalanceProof) {
+				p.Balance, p.Nonce = 0, 0
+				p.Leaf = &ProofLeaf{stateKey(p.Account), stateValue(proof.Balance, proof.Nonce)}
+			}, false},
+		}
+		if len(proof.Siblings) > 0 {
+			tests = append(tests, balanceProofCase{"sibling dropped", func(p *BalanceProof) { p.Siblings = p.Siblings[1:] }, false})
+		}
+		// the absence of an account may also prove the absence of others
+		if present {
+			tests = append(tests, balanceProofCase{"other account", func(p *BalanceProof) { _, p.Account = testKey(name + "-other") }, false})
+		}
+
+		for _, tt := range tests {
+			p := proof
+			p.Siblings = append([]Hash{}, proof.Siblings...)
+			tt.change(&p)
+
+			if err := p.Verify(); (err == nil) != tt.valid {
+				t.Errorf("%s, %s: got error %v, want valid %t", name, tt.name, err, tt.valid)
+			}
+		}
+	}
+
+	if absentLeaves == 0 || absentEmpty == 0 {
+		t.Errorf("got %d absent accounts proven by another leaf and %d by an empty subtree, want both", absentLeaves, absentEmpty)
+	}
+}
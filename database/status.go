@@ -0,0 +1,120 @@
+package database
+
+import "fmt"
+
+// The stages of a txn reported by TxnStatus
+const (
+	TxnUnknown  = "unknown"
+	TxnPending  = "pending"
+	TxnIncluded = "included"
+	TxnDropped  = "dropped"
+	TxnOrphaned = "orphaned"
+)
+
+// maxTxnFates bounds the number of dropped and orphaned txns a node remembers
+const maxTxnFates = 10000
+
+// TxnStatus stores the stage of a txn. Location is the block of an included
+// txn or the block rolled back by a reorg for an orphaned txn. Reason tells
+// why a txn was dropped or what happened to an orphaned txn.
+type TxnStatus struct {
+	Hash          Hash         `json:"hash"`
+	Status        string       `json:"status"`
+	Location      *TxnLocation `json:"location,omitempty"`
+	Confirmations uint64       `json:"confirmations"`
+	Reason        string       `json:"reason,omitempty"`
+	final         bool
+}
+
+// Final reports whether the txn is neither included nor pending and only
+// reaches a block if it is submitted again, as a dropped txn or an
+// orphaned txn which did not go back to the mempool
+func (st TxnStatus) Final() bool {
+	return st.final
+}
+
+// txnFates remembers the txns which left the mempool without being included
+// and the txns whose block was rolled back, the oldest are forgotten first.
+// Ordered holds the txns in the order, each once.
+type txnFates struct {
+	dropped  map[Hash]string
+	orphaned map[Hash]TxnLocation
+	ordered  map[Hash]bool
+	order    []Hash
+}
+
+// newTxnFates returns an empty record of txn fates
+func newTxnFates() *txnFates {
+	return &txnFates{make(map[Hash]string), make(map[Hash]TxnLocation), make(map[Hash]bool), make([]Hash, 0)}
+}
+
+// remember adds the txn to the order of txns to forget, a txn already
+// in the order keeps its place so that the order never outgrows the fates
+func (f *txnFates) remember(hash Hash) {
+	if f.ordered[hash] {
+		return
+	}
+	f.ordered[hash] = true
+	f.order = append(f.order, hash)
+	for len(f.order) > maxTxnFates {
+		delete(f.dropped, f.order[0])
+		delete(f.orphaned, f.order[0])
+		delete(f.ordered, f.order[0])
+		f.order = f.order[1:]
+	}
+}
+
+// drop records why the txn was removed from the mempool
+func (f *txnFates) drop(hash Hash, reason error) {
+	f.dropped[hash] = reason.Error()
+	f.remember(hash)
+}
+
+// orphan records the block of the txn which was rolled back
+func (f *txnFates) orphan(hash Hash, location TxnLocation) {
+	f.orphaned[hash] = location
+	f.remember(hash)
+}
+
+// forget removes the fate of a txn which is pending or included again
+func (f *txnFates) forget(hash Hash, included bool) {
+	delete(f.dropped, hash)
+	if included {
+		delete(f.orphaned, hash)
+	}
+}
+
+// TxnStatus returns the stage of the txn with the given hash
+func (s *State) TxnStatus(hash Hash) TxnStatus {
+	if record, ok := s.txnIndex[hash]; ok {
+		location := record.location
+		return TxnStatus{hash, TxnIncluded, &location, s.Confirmations(location.BlockNumber), "", false}
+	}
+
+	_, pending := s.txnMempool.hashes[hash]
+	reason, dropped := s.txnFates.dropped[hash]
+
+	if location, ok := s.txnFates.orphaned[hash]; ok {
+		status := TxnStatus{Hash: hash, Status: TxnOrphaned, Location: &location, final: !pending}
+		rolledBack := fmt.Sprintf("block %d %x was rolled back by a reorg", location.BlockNumber, location.BlockHash)
+		switch {
+		case pending:
+			status.Reason = rolledBack + ", the txn is pending again"
+		case dropped:
+			status.Reason = rolledBack + ", the txn was dropped: " + reason
+		default:
+			status.Reason = rolledBack
+		}
+		return status
+	}
+
+	if pending {
+		return TxnStatus{Hash: hash, Status: TxnPending}
+	}
+
+	if dropped {
+		return TxnStatus{Hash: hash, Status: TxnDropped, Reason: reason, final: true}
+	}
+
+	return TxnStatus{Hash: hash, Status: TxnUnknown}
+}
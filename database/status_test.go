@@ -0,0 +1,40 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTxnFatesRemember(t *testing.T) {
+	f := newTxnFates()
+	first := Hash{1}
+
+	// a txn dropped, pending again, orphaned and dropped once more
+	// is ordered once
+	f.drop(first, fmt.Errorf("replaced"))
+	f.forget(first, false)
+	f.orphan(first, TxnLocation{BlockNumber: 1})
+	f.drop(first, fmt.Errorf("expired"))
+	if len(f.order) != 1 {
+		t.Fatalf("got an order of %d txns, want 1", len(f.order))
+	}
+
+	// filling the order forgets the oldest txns only
+	for i := 1; i < maxTxnFates; i++ {
+		f.drop(Hash{2, byte(i), byte(i >> 8)}, fmt.Errorf("expired"))
+	}
+	if f.dropped[first] != "expired" {
+		t.Errorf("got dropped reason %q, want %q", f.dropped[first], "expired")
+	}
+
+	f.drop(Hash{3}, fmt.Errorf("expired"))
+	if _, ok := f.dropped[first]; ok {
+		t.Errorf("the oldest txn is still dropped beyond %d txns", maxTxnFates)
+	}
+	if _, ok := f.orphaned[first]; ok {
+		t.Errorf("the oldest txn is still orphaned beyond %d txns", maxTxnFates)
+	}
+	if len(f.order) != maxTxnFates || len(f.ordered) != maxTxnFates {
+		t.Errorf("got %d ordered txns in an order of %d, want %d", len(f.ordered), len(f.order), maxTxnFates)
+	}
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// medianTimeBlocks is the number of latest blocks whose
+// median time a new block must be later than
+const medianTimeBlocks = 11
+
+// medianTimePast returns the median time of the block with the given hash
+// and its latest ancestors. Before the first block it is the genesis time.
+func (s *State) medianTimePast(hash Hash) uint64 {
+	times := make([]uint64, 0, medianTimeBlocks)
+	for len(times) < medianTimeBlocks {
+		node, ok := s.blocks[hash]
+		if !ok {
+			break
+		}
+		times = append(times, node.block.Header.Time)
+		hash = node.block.Header.Parent
+	}
+
+	if len(times) == 0 {
+		return s.genesisTime
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2]
+}
+
+// MedianTimePast returns the median time of the latest blocks
+func (s *State) MedianTimePast() uint64 {
+	return s.medianTimePast(s.latestBlockHash)
+}
+
+// checkBlockTime validates that the block is later than the median time
+// of its latest ancestors and not too far in the future of local time.
+// The first block must not be older than the genesis.
+func (s *State) checkBlockTime(b Block) error {
+	if b.Header.Number == 0 {
+		if b.Header.Time < s.genesisTime {
+			return fmt.Errorf("block 0 time %d is before the genesis time %d", b.Header.Time, s.genesisTime)
+		}
+	} else if median := s.medianTimePast(b.Header.Parent); b.Header.Time <= median {
+		return fmt.Errorf("block %d time %d is not later than the median time %d of the latest blocks", b.Header.Number, b.Header.Time, median)
+	}
+
+	maxTime := uint64(time.Now().Unix()) + s.consensus.MaxFutureBlockTime
+	if b.Header.Time > maxTime {
+		return fmt.Errorf("block %d time %d is more than %d seconds in the future", b.Header.Number, b.Header.Time, s.consensus.MaxFutureBlockTime)
+	}
+
+	return nil
+}
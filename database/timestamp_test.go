@@ -0,0 +1,54 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckBlockTime(t *testing.T) {
+	s, _ := newTestState(t, nil, "alice")
+	genesisTime := uint64(testGenesisTime.Unix())
+
+	// blocks 0 to 4 are dated a second apart after the genesis
+	blocks := make([]Block, 5)
+	for i := range blocks {
+		blocks[i] = produceTestBlock(t, s, "producer")
+	}
+	now := uint64(time.Now().Unix())
+	future := s.Consensus().MaxFutureBlockTime
+
+	tests := []struct {
+		name   string
+		number uint64
+		parent Hash
+		time   uint64
+		valid  bool
+	}{
+		{"first block at the genesis time", 0, s.GenesisHash(), genesisTime, true},
+		{"first block before the genesis", 0, s.GenesisHash(), genesisTime - 1, false},
+		{"later than the median", 5, blockHash(t, blocks[4]), genesisTime + 4, true},
+		{"at the median", 5, blockHash(t, blocks[4]), genesisTime + 3, false},
+		{"before the median", 5, blockHash(t, blocks[4]), genesisTime + 1, false},
+		{"median of a side branch", 2, blockHash(t, blocks[1]), genesisTime + 3, true},
+		{"at the median of a side branch", 2, blockHash(t, blocks[1]), genesisTime + 2, false},
+		{"within the future window", 5, blockHash(t, blocks[4]), now + future - 1, true},
+		{"beyond the future window", 5, blockHash(t, blocks[4]), now + future + 10, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Block{Header: BlockHeader{Number: tt.number, Parent: tt.parent, Time: tt.time}}
+			if err := s.checkBlockTime(b); (err == nil) != tt.valid {
+				t.Errorf("got error %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+
+	// the median is taken over the latest blocks only
+	for i := 0; i < medianTimeBlocks; i++ {
+		produceTestBlock(t, s, "producer")
+	}
+	if median, want := s.MedianTimePast(), testBlockTime(s.NextBlockNumber()-1-medianTimeBlocks/2); median != want {
+		t.Errorf("got median time %d, want %d", median, want)
+	}
+}
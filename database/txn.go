@@ -1,27 +1,121 @@
 package database
 
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
 // Account is an individual
 type Account string
 
-// Txn stores info about each txn
+// Txn stores info about each txn.
+// ChainID binds the txn to a single chain so that
+// a signed txn cannot be replayed on another chain.
+// Fee is paid by the sender to the block producer on top of Value.
 type Txn struct {
-	From  Account `json:"from"`
-	To    Account `json:"to"`
-	Value uint    `json:"value"`
-	Data  string  `json:"data"`
+	ChainID string  `json:"chain_id"`
+	From    Account `json:"from"`
+	To      Account `json:"to"`
+	Value   uint    `json:"value"`
+	Fee     uint    `json:"fee"`
+	Nonce   uint64  `json:"nonce"`
+	Data    string  `json:"data"`
+}
+
+// SignedTxn stores a txn along with the public key
+// of the sender and its signature over the txn.
+// Txns from a multisig account carry the multisig
+// and the signatures of its keys instead.
+type SignedTxn struct {
+	Txn
+	PubKey   Bytes            `json:"pub_key"`
+	Sig      Bytes            `json:"signature"`
+	Multisig *MultisigAccount `json:"multisig,omitempty"`
+	Sigs     []Signature      `json:"signatures,omitempty"`
 }
 
-// NewAccount creates a new account with the given value
-func NewAccount(value string) Account {
-	return Account(value)
+// NewAccount returns the account with the given address
+// after checking the address and its checksum
+func NewAccount(value string) (Account, error) {
+	account := Account(value)
+	if err := account.Validate(); err != nil {
+		return "", err
+	}
+	return account, nil
 }
 
 // NewTxn creates a new txn based on the given details
-func NewTxn(from Account, to Account, value uint, data string) Txn {
-	return Txn{from, to, value, data}
+// The nonce must be the next nonce of the sender
+func NewTxn(chainID string, from Account, to Account, value uint, fee uint, nonce uint64, data string) Txn {
+	return Txn{chainID, from, to, value, fee, nonce, data}
+}
+
+// Cost returns the amount the sender spends on the txn
+func (t Txn) Cost() uint {
+	return t.Value + t.Fee
+}
+
+// NewSignedTxn signs the txn with the given private key
+func NewSignedTxn(txn Txn, privKey ed25519.PrivateKey) (SignedTxn, error) {
+	encoded, err := txn.Encode()
+	if err != nil {
+		return SignedTxn{}, err
+	}
+
+	pubKey := privKey.Public().(ed25519.PublicKey)
+	return SignedTxn{Txn: txn, PubKey: Bytes(pubKey), Sig: sign(encoded, privKey)}, nil
 }
 
 // IsReward() checks if the txn is a reward
 func (t Txn) IsReward() bool {
 	return t.Data == "reward"
 }
+
+// Encode returns the canonical encoding of the txn which is signed
+func (t Txn) Encode() ([]byte, error) {
+	return json.Marshal(t)
+}
+
+// Hash returns the sha256 hash of the encoded txn.
+// The signature is not part of the hash so the hash
+// identifies the txn before and after it is signed.
+func (t Txn) Hash() (Hash, error) {
+	encoded, err := t.Encode()
+	if err != nil {
+		return Hash{}, err
+	}
+	return sha256.Sum256(encoded), nil
+}
+
+// IsAuthentic checks that the txn was signed by the owner of the sender account
+// and that the txn was not modified after it was signed
+func (t SignedTxn) IsAuthentic() error {
+	if t.Multisig != nil {
+		return t.isAuthenticMultisig()
+	}
+
+	if len(t.Sigs) != 0 {
+		return fmt.Errorf("txn from %s carries multisig signatures without a multisig", t.From)
+	}
+
+	if len(t.Sig) == 0 {
+		return fmt.Errorf("txn from %s is not signed", t.From)
+	}
+
+	if NewAccountFromPubKey(ed25519.PublicKey(t.PubKey)) != t.From {
+		return fmt.Errorf("public key does not belong to sender %s", t.From)
+	}
+
+	encoded, err := t.Txn.Encode()
+	if err != nil {
+		return err
+	}
+
+	if !verify(encoded, t.PubKey, t.Sig) {
+		return fmt.Errorf("invalid signature on txn from %s", t.From)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package node
+
+import (
+	"blockchain-sample/database"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// relayWorkers is the number of txns relayed to peers at once
+	relayWorkers = 8
+	// relayQueueSize is the number of relays waiting for a worker,
+	// further relays are skipped until the queue has room again
+	relayQueueSize = 1000
+	// maxSeenTxns is the number of txn hashes remembered as seen
+	maxSeenTxns = 10000
+)
+
+// relayClient sends pending txns to peers without
+// waiting for unresponsive peers forever
+var relayClient = &http.Client{Timeout: 5 * time.Second}
+
+// relay is a pending txn to be pushed to a peer
+type relay struct {
+	peer    PeerNode
+	txn     database.SignedTxn
+	txnHash database.Hash
+}
+
+// seenTxns remembers the hashes of the latest txns the node has
+// added, forgetting the oldest ones beyond maxSeenTxns
+type seenTxns struct {
+	hashes map[database.Hash]struct{}
+	order  []database.Hash
+	lock   sync.Mutex
+}
+
+func newSeenTxns() *seenTxns {
+	return &seenTxns{hashes: make(map[database.Hash]struct{})}
+}
+
+// has reports whether the txn was seen
+func (s *seenTxns) has(hash database.Hash) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, ok := s.hashes[hash]
+	return ok
+}
+
+// add marks the txn as seen and reports whether it was not seen before
+func (s *seenTxns) add(hash database.Hash) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.hashes[hash]; ok {
+		return false
+	}
+	if len(s.order) == maxSeenTxns {
+		delete(s.hashes, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.hashes[hash] = struct{}{}
+	s.order = append(s.order, hash)
+	return true
+}
+
+// addPendingTxn adds a txn submitted by a client or relayed by a peer to the
+// mempool and relays it to the known peers but the origin, the address of
+// the peer which relayed it or empty for clients. A txn is relayed only the
+// first time the node sees it, and txns relayed again, such as txns the
+// mempool has since evicted or expired, are refused.
+func (n *Node) addPendingTxn(txn database.SignedTxn, origin string) (database.Hash, error) {
+	txnHash, err := txn.Hash()
+	if err != nil {
+		return database.Hash{}, err
+	}
+	if origin != "" && n.seenTxns.has(txnHash) {
+		return database.Hash{}, fmt.Errorf("txn %x was already relayed", txnHash)
+	}
+
+	// even a refused txn may have expired other txns
+	n.stateLock.Lock()
+	txnHash, err = n.state.AddPendingTxn(txn)
+	n.notifyPendingTxnsChanged()
+	n.stateLock.Unlock()
+	if err != nil {
+		return database.Hash{}, err
+	}
+
+	// wake the producer without waiting for it
+	select {
+	case n.pendingTxnAdded <- struct{}{}:
+	default:
+	}
+
+	if !n.seenTxns.add(txnHash) {
+		return txnHash, nil
+	}
+	for address, peer := range n.KnownPeers() {
+		if address == origin || (peer.IP == n.ip && peer.Port == n.port) {
+			continue
+		}
+
+		select {
+		case n.relayQueue <- relay{peer, txn, txnHash}:
+		default:
+			fmt.Printf("[-] Relay queue is full, txn %x is not relayed to peer %s\n", txnHash, address)
+		}
+	}
+	return txnHash, nil
+}
+
+// relayTxns pushes the queued txns to the peers until the context is done
+func (n *Node) relayTxns(ctx context.Context) {
+	for {
+		select {
+		case r := <-n.relayQueue:
+			n.relayTxn(r)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// relayTxn pushes the pending txn to the peer, telling
+// the peer the address of the node as the origin
+func (n *Node) relayTxn(r relay) {
+	txnJson, err := json.Marshal(r.txn)
+	if err != nil {
+		fmt.Println("[-] ", err)
+		return
+	}
+
+	url := fmt.Sprintf("http://%s%s?%s=%s&%s=%d", r.peer.TcpAddress(), endpointTxnRelay, endpointTxnRelayQueryKeyIP, n.ip, endpointTxnRelayQueryKeyPort, n.port)
+	res, err := relayClient.Post(url, "application/json", bytes.NewReader(txnJson))
+	if err != nil {
+		fmt.Printf("[-] Unable to relay txn %x to peer %s: %s\n", r.txnHash, r.peer.TcpAddress(), err)
+		return
+	}
+	// the peer refuses txns it already knows, which is expected
+	res.Body.Close()
+}
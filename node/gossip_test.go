@@ -0,0 +1,137 @@
+package node
+
+import (
+	"blockchain-sample/database"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// queuedRelays empties the relay queue of the node
+// and returns the addresses of the peers by txn
+func queuedRelays(n *Node) map[database.Hash][]string {
+	relays := make(map[database.Hash][]string)
+	for {
+		select {
+		case r := <-n.relayQueue:
+			relays[r.txnHash] = append(relays[r.txnHash], r.peer.TcpAddress())
+		default:
+			return relays
+		}
+	}
+}
+
+// checkRelays checks that the node queued the txn for the peers only
+func checkRelays(t *testing.T, n *Node, txnHash database.Hash, peers ...PeerNode) {
+	t.Helper()
+
+	relays := queuedRelays(n)
+	want := make(map[string]bool)
+	for _, peer := range peers {
+		want[peer.TcpAddress()] = true
+	}
+
+	if len(relays[txnHash]) != len(want) {
+		t.Errorf("txn %x is relayed to %v, want %v", txnHash, relays[txnHash], want)
+	}
+	for _, address := range relays[txnHash] {
+		if !want[address] {
+			t.Errorf("txn %x is relayed to %s, want %v", txnHash, address, want)
+		}
+	}
+	for hash := range relays {
+		if hash != txnHash {
+			t.Errorf("txn %x is relayed, want only %x", hash, txnHash)
+		}
+	}
+}
+
+func TestRelayTxn(t *testing.T) {
+	// node a relays to node b, which is served over HTTP, and peer c
+	a := newTestNode(t, newTestDataDir(t, "alice"), nil)
+	b := newTestNode(t, newTestDataDir(t, "alice"), nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		txnRelayHandler(w, r, b)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.ParseUint(serverURL.Port(), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.ip, a.port = "127.0.0.1", 9001
+	b.ip, b.port = serverURL.Hostname(), port
+	peerA := PeerNode{IP: a.ip, Port: a.port}
+	peerB := PeerNode{IP: b.ip, Port: b.port}
+	peerC := PeerNode{IP: "127.0.0.1", Port: 9003}
+	a.AddPeer(peerB)
+	a.AddPeer(peerC)
+	b.AddPeer(peerA)
+	b.AddPeer(peerC)
+
+	// a txn submitted by a client is relayed to every peer
+	txn := testTxn(t, "alice", "bob", 10, 1, 0)
+	txnHash, err := a.addPendingTxn(txn, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRelays(t, a, txnHash, peerB, peerC)
+
+	// b relays the txn further but not back to a, its origin
+	relayToB := relay{peerB, txn, txnHash}
+	a.relayTxn(relayToB)
+	if status := b.state.TxnStatus(txnHash); status.Status != database.TxnPending {
+		t.Fatalf("relayed txn is %s, want %s", status.Status, database.TxnPending)
+	}
+	checkRelays(t, b, txnHash, peerC)
+
+	// a txn relayed again is refused and not relayed once more
+	a.relayTxn(relayToB)
+	checkRelays(t, b, database.Hash{})
+
+	// the same holds once the mempool dropped the txn,
+	// which would otherwise be pending again
+	replacement := testTxn(t, "alice", "bob", 10, 2, 0)
+	replacementHash, err := b.addPendingTxn(replacement, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRelays(t, b, replacementHash, peerA, peerC)
+	if status := b.state.TxnStatus(txnHash); status.Status != database.TxnDropped {
+		t.Fatalf("replaced txn is %s, want %s", status.Status, database.TxnDropped)
+	}
+
+	if _, err := b.addPendingTxn(txn, peerC.TcpAddress()); err == nil {
+		t.Errorf("got no error for a txn relayed after it was dropped")
+	}
+	if status := b.state.TxnStatus(txnHash); status.Status != database.TxnDropped {
+		t.Errorf("replaced txn relayed again is %s, want %s", status.Status, database.TxnDropped)
+	}
+	checkRelays(t, b, database.Hash{})
+}
+
+func TestSeenTxns(t *testing.T) {
+	seen := newSeenTxns()
+	for i := 0; i <= maxSeenTxns; i++ {
+		if !seen.add(database.Hash{byte(i), byte(i >> 8), byte(i >> 16)}) {
+			t.Fatalf("txn %d was seen before it was added", i)
+		}
+	}
+
+	if seen.add(database.Hash{1}) {
+		t.Errorf("txn 1 was not seen after it was added")
+	}
+	if seen.has(database.Hash{0}) {
+		t.Errorf("the oldest txn is still seen beyond %d txns", maxSeenTxns)
+	}
+	if len(seen.hashes) != maxSeenTxns || len(seen.order) != maxSeenTxns {
+		t.Errorf("got %d seen txns in an order of %d, want %d", len(seen.hashes), len(seen.order), maxSeenTxns)
+	}
+}
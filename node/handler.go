@@ -5,23 +5,42 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// statusHandler responds with the latest block hash and height
+// statusHandler responds with the latest block hash, height and total work
 func statusHandler(w http.ResponseWriter, r *http.Request, node *Node) {
-	res := StatusRes{node.state.LatestBlockHash(), node.state.LatestBlock().Header.Number, node.knownPeers}
+	res := StatusRes{node.state.ChainID(), node.state.GenesisHash(), node.state.LatestBlockHash(), node.state.LatestBlock().Header.Number, node.state.TotalWork(), node.KnownPeers()}
 	writeRes(w, res)
 }
 
-// listBalanceHandler responds with the
-// latest block hash and the current balances
+// listBalanceHandler responds with the latest block hash,
+// the current balances and the next nonce of each account
 func listBalancesHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
-	writeRes(w, BalancesRes{state.LatestBlockHash(), state.Balances})
+	writeRes(w, BalancesRes{state.LatestBlockHash(), state.Balances, state.Nonces, state.Fees})
 }
 
-// txnAddHandler adds the given valid transaction to the current state
-func txnAddHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+// balanceGetHandler responds with the balance of the account in the url
+// path and the proof that the latest block header commits to it
+func balanceGetHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	account, err := database.NewAccount(strings.TrimPrefix(r.URL.Path, endpointBalance))
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	proof, err := state.BalanceProof(account)
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+	writeRes(w, proof)
+}
+
+// txnAddHandler adds the given valid transaction to the
+// mempool of the node and relays it to the known peers
+func txnAddHandler(w http.ResponseWriter, r *http.Request, node *Node) {
 	req := TxnAddReq{}
 	err := readReq(r, &req)
 	if err != nil {
@@ -29,49 +48,204 @@ func txnAddHandler(w http.ResponseWriter, r *http.Request, state *database.State
 		return
 	}
 
-	txn := database.Txn{
-		From:  database.NewAccount(req.From),
-		To:    database.NewAccount(req.To),
-		Value: req.Value,
-		Data:  req.Data}
+	from, err := database.NewAccount(req.From)
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+	to, err := database.NewAccount(req.To)
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	txn := database.SignedTxn{
+		Txn: database.Txn{
+			ChainID: req.ChainID,
+			From:    from,
+			To:      to,
+			Value:   req.Value,
+			Fee:     req.Fee,
+			Nonce:   req.Nonce,
+			Data:    req.Data},
+		Multisig: req.Multisig,
+		Sigs:     req.Sigs,
+	}
+	if err := txn.PubKey.UnmarshalText([]byte(req.PubKey)); err != nil {
+		writeErrRes(w, fmt.Errorf("invalid public key: %s", err))
+		return
+	}
+	if err := txn.Sig.UnmarshalText([]byte(req.Sig)); err != nil {
+		writeErrRes(w, fmt.Errorf("invalid signature: %s", err))
+		return
+	}
 
-	block := database.NewBlock(
-		state.LatestBlockHash(),
-		state.NextBlockNumber(),
-		uint64(time.Now().Unix()),
-		[]database.Txn{txn},
-	)
-	hash, err := state.AddBlock(block)
+	txnHash, err := node.addPendingTxn(txn, "")
 	if err != nil {
 		writeErrRes(w, err)
+		return
 	}
-	writeRes(w, TxnAddRes{Hash: hash})
+
+	writeRes(w, TxnAddRes{Hash: txnHash})
 }
 
-// syncHandler fetches newer block if present
-func syncHandler(w http.ResponseWriter, r *http.Request, dataDir string) {
-	//get target node's latest block hash
-	reqHash := r.URL.Query().Get(endpointSyncQueryKeyFromBlock)
+// txnGetHandler responds with the txn whose hash is in the url path,
+// or with its inclusion proof if the path ends with endpointTxnProof
+func txnGetHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	path := strings.TrimPrefix(r.URL.Path, endpointTxn)
+	isProof := strings.HasSuffix(path, endpointTxnProof)
 
 	hash := database.Hash{}
-	err := hash.UnmarshalText([]byte(reqHash))
+	err := hash.UnmarshalText([]byte(strings.TrimSuffix(path, endpointTxnProof)))
+	if err != nil {
+		writeErrRes(w, fmt.Errorf("invalid txn hash: %s", err))
+		return
+	}
+
+	if isProof {
+		proof, err := state.TxnProof(hash)
+		if err != nil {
+			writeErrRes(w, err)
+			return
+		}
+		writeRes(w, proof)
+		return
+	}
+
+	txn, location, ok := state.GetTxn(hash)
+	if !ok {
+		writeErrRes(w, fmt.Errorf("txn %x not found", hash))
+		return
+	}
+
+	writeRes(w, TxnRes{hash, txn, location, state.Confirmations(location.BlockNumber)})
+}
+
+// a status request waiting for confirmations gives up after the timeout
+const (
+	defaultTxnStatusTimeout = 1 * time.Minute
+	maxTxnStatusTimeout     = 10 * time.Minute
+)
+
+// txnStatusHandler responds with the stage of the txn whose hash is in the
+// url path. With the confirmations query it waits until the txn has as many
+// confirmations, can no longer be included or the timeout passes, whichever
+// comes first.
+func txnStatusHandler(w http.ResponseWriter, r *http.Request, node *Node) {
+	hash := database.Hash{}
+	err := hash.UnmarshalText([]byte(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, endpointTxn), endpointTxnStatus)))
+	if err != nil {
+		writeErrRes(w, fmt.Errorf("invalid txn hash: %s", err))
+		return
+	}
+
+	confirmations := uint64(0)
+	if value := r.URL.Query().Get(endpointTxnStatusQueryKeyConfirmations); value != "" {
+		confirmations, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeErrRes(w, fmt.Errorf("invalid confirmations %q: %s", value, err))
+			return
+		}
+	}
+
+	timeout := defaultTxnStatusTimeout
+	if value := r.URL.Query().Get(endpointTxnStatusQueryKeyTimeout); value != "" {
+		timeout, err = time.ParseDuration(value)
+		if err != nil {
+			writeErrRes(w, fmt.Errorf("invalid timeout %q: %s", value, err))
+			return
+		}
+		if timeout > maxTxnStatusTimeout {
+			timeout = maxTxnStatusTimeout
+		}
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		node.stateLock.RLock()
+		status := node.state.TxnStatus(hash)
+		blockAdded := node.blockAdded
+		pendingTxnsChanged := node.pendingTxnsChanged
+		node.stateLock.RUnlock()
+
+		if status.Confirmations >= confirmations || status.Final() {
+			writeRes(w, status)
+			return
+		}
+
+		select {
+		case <-blockAdded:
+		case <-pendingTxnsChanged:
+		case <-deadline.C:
+			writeRes(w, status)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// txnRelayHandler adds a pending txn relayed by a peer to the mempool
+// and relays it further, but back to the peer, if the node did not
+// see it yet
+func txnRelayHandler(w http.ResponseWriter, r *http.Request, node *Node) {
+	ip := r.URL.Query().Get(endpointTxnRelayQueryKeyIP)
+	port, err := strconv.ParseUint(r.URL.Query().Get(endpointTxnRelayQueryKeyPort), 10, 32)
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+	origin := NewPeerNode(ip, port, false, true)
+
+	txn := database.SignedTxn{}
+	if err := readReq(r, &txn); err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	txnHash, err := node.addPendingTxn(txn, origin.TcpAddress())
 	if err != nil {
 		writeErrRes(w, err)
 		return
 	}
+	writeRes(w, TxnAddRes{Hash: txnHash})
+}
+
+// syncHandler responds with the canonical blocks after the
+// requested block, from the fork point for a block of a side branch
+func syncHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	//get target node's latest block hash
+	reqHash := r.URL.Query().Get(endpointSyncQueryKeyFromBlock)
 
-	blocks, err := database.GetBlocksAfter(hash, dataDir)
+	hash := database.Hash{}
+	err := hash.UnmarshalText([]byte(reqHash))
 	if err != nil {
 		writeErrRes(w, err)
 		return
 	}
 
-	writeRes(w, SyncRes{Blocks: blocks})
+	writeRes(w, SyncRes{Blocks: state.BlocksAfter(hash)})
 }
 
 func addPeerHandler(w http.ResponseWriter, r *http.Request, node *Node) {
 	ip := r.URL.Query().Get(endpointAddPeerQueryKeyIP)
 	port := r.URL.Query().Get(endpointAddPeerQueryKeyPort)
+	chainID := r.URL.Query().Get(endpointAddPeerQueryKeyChainID)
+	genesisHash := r.URL.Query().Get(endpointAddPeerQueryKeyGenesis)
+
+	// only peers of the same chain may join
+	if chainID != node.state.ChainID() {
+		writeRes(w, AddPeerRes{false, fmt.Sprintf("peer is on chain %q, not %q", chainID, node.state.ChainID())})
+		return
+	}
+
+	// and the chain must be built on the same genesis
+	if genesisHash != fmt.Sprintf("%x", node.state.GenesisHash()) {
+		writeRes(w, AddPeerRes{false, fmt.Sprintf("peer has genesis %s, not %x", genesisHash, node.state.GenesisHash())})
+		return
+	}
 
 	peerPort, err := strconv.ParseUint(port, 10, 32)
 	if err != nil {
@@ -0,0 +1,82 @@
+package node
+
+import (
+	"blockchain-sample/database"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// requestTxnStatus starts a status request of the txn waiting for a
+// confirmation and returns the channel of its response
+func requestTxnStatus(t *testing.T, n *Node, txnHash database.Hash, timeout time.Duration) chan database.TxnStatus {
+	t.Helper()
+
+	url := fmt.Sprintf("%s%x%s?%s=1&%s=%s", endpointTxn, txnHash, endpointTxnStatus, endpointTxnStatusQueryKeyConfirmations, endpointTxnStatusQueryKeyTimeout, timeout)
+	res := make(chan database.TxnStatus, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		txnStatusHandler(rec, httptest.NewRequest("GET", url, nil), n)
+
+		status := database.TxnStatus{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Errorf("invalid status response %q: %s", rec.Body.String(), err)
+		}
+		res <- status
+	}()
+	return res
+}
+
+// waitTxnStatus waits for the response of a status request
+func waitTxnStatus(t *testing.T, res chan database.TxnStatus) database.TxnStatus {
+	t.Helper()
+
+	select {
+	case status := <-res:
+		return status
+	case <-time.After(30 * time.Second):
+		t.Fatal("status request did not return")
+		return database.TxnStatus{}
+	}
+}
+
+func TestTxnStatusLongPoll(t *testing.T) {
+	producerKey, _ := testKey("producer")
+	n := newTestNode(t, newTestDataDir(t, "alice"), producerKey)
+
+	txn := testTxn(t, "alice", "bob", 10, 1, 0)
+	txnHash, err := n.addPendingTxn(txn, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without changes the request waits until the timeout
+	status := waitTxnStatus(t, requestTxnStatus(t, n, txnHash, 10*time.Millisecond))
+	if status.Status != database.TxnPending {
+		t.Fatalf("got status %s, want %s", status.Status, database.TxnPending)
+	}
+
+	// a txn replaced in the mempool can no longer be confirmed
+	res := requestTxnStatus(t, n, txnHash, time.Minute)
+	replacement := testTxn(t, "alice", "bob", 10, 2, 0)
+	replacementHash, err := n.addPendingTxn(replacement, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := waitTxnStatus(t, res); status.Status != database.TxnDropped {
+		t.Errorf("got status %s of the replaced txn, want %s", status.Status, database.TxnDropped)
+	}
+
+	// a txn included in a new block is confirmed
+	res = requestTxnStatus(t, n, replacementHash, time.Minute)
+	if _, err := n.produceBlock(context.Background(), []database.SignedTxn{replacement}); err != nil {
+		t.Fatal(err)
+	}
+	status = waitTxnStatus(t, res)
+	if status.Status != database.TxnIncluded || status.Confirmations != 1 {
+		t.Errorf("got status %s with %d confirmations, want %s with 1", status.Status, status.Confirmations, database.TxnIncluded)
+	}
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"blockchain-sample/database"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// testChainID is the chain of the data dirs of newTestDataDir
+const testChainID = "test"
+
+// testKey returns the key and account of a test user
+func testKey(name string) (ed25519.PrivateKey, database.Account) {
+	seed := sha256.Sum256([]byte(name))
+	privKey := ed25519.NewKeyFromSeed(seed[:])
+	return privKey, database.NewAccountFromPubKey(privKey.Public().(ed25519.PublicKey))
+}
+
+// newTestDataDir returns a data dir whose genesis uses the instant
+// engine and funds the given users with 1000 paisa each
+func newTestDataDir(t *testing.T, funded ...string) string {
+	t.Helper()
+
+	balances := make(map[database.Account]uint)
+	for _, name := range funded {
+		_, account := testKey(name)
+		balances[account] = 1000
+	}
+
+	content, err := json.Marshal(map[string]interface{}{
+		"genesis_time": "2021-12-17T00:00:00Z",
+		"chain_id":     testChainID,
+		"balances":     balances,
+		"consensus": database.ConsensusParams{
+			Engine:             "instant",
+			MaxBlockTxns:       10,
+			BlockInterval:      1,
+			BlockReward:        10,
+			HalvingInterval:    100,
+			MaxSupply:          1000000,
+			MaxFutureBlockTime: 120,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := t.TempDir()
+	if _, err := database.InitDataDir(dataDir, content); err != nil {
+		t.Fatal(err)
+	}
+	return dataDir
+}
+
+// testTxn returns a txn signed by the sender
+func testTxn(t *testing.T, from, to string, value, fee uint, nonce uint64) database.SignedTxn {
+	t.Helper()
+
+	privKey, fromAccount := testKey(from)
+	_, toAccount := testKey(to)
+	txn, err := database.NewSignedTxn(database.NewTxn(testChainID, fromAccount, toAccount, value, fee, nonce, ""), privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txn
+}
+
+// newTestNode returns a node without peers on the state of the data dir,
+// the data dir is initialized with the default genesis if it is empty.
+// The HTTP server of the node is not started.
+func newTestNode(t *testing.T, dataDir string, producerKey ed25519.PrivateKey) *Node {
+	t.Helper()
+
+	n := New(dataDir, DefaultIP, DefaultHttpPort, producerKey, ProducerConfig{Interval: 10 * time.Millisecond}, PeerNode{}, database.Finality{}, database.DefaultMempoolLimits)
+	n.RemovePeer(PeerNode{})
+
+	state, err := database.NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { state.Close() })
+
+	n.state = state
+	n.engine = state.Engine()
+	return n
+}
+
+// waitBlockAdded waits until the blockAdded channel of a node is closed
+func waitBlockAdded(t *testing.T, blockAdded chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-blockAdded:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("no block was added")
+	}
+}
@@ -1,10 +1,15 @@
 package node
 
 import (
+	"blockchain-sample/consensus"
 	"blockchain-sample/database"
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -12,33 +17,78 @@ const (
 	DefaultHttpPort = 8080
 	endpointStatus  = "/node/status"
 
+	endpointBalance = "/balances/"
+
+	endpointTxnAdd   = "/txn/add"
+	endpointTxn      = "/txn/"
+	endpointTxnProof = "/proof"
+
+	endpointTxnStatus                      = "/status"
+	endpointTxnStatusQueryKeyConfirmations = "confirmations"
+	endpointTxnStatusQueryKeyTimeout       = "timeout"
+
 	endpointSync                  = "/node/sync"
 	endpointSyncQueryKeyFromBlock = "fromBlock"
 
+	endpointTxnRelay             = "/node/txn"
+	endpointTxnRelayQueryKeyIP   = "ip"
+	endpointTxnRelayQueryKeyPort = "port"
+
 	endpointAddPeer             = "/node/peer"
 	endpointAddPeerQueryKeyIP   = "ip"
 	endpointAddPeerQueryKeyPort = "port"
+
+	endpointAddPeerQueryKeyChainID = "chain_id"
+	endpointAddPeerQueryKeyGenesis = "genesis_hash"
+
+	// maxExpiryInterval is the longest time between two
+	// checks for expired pending txns
+	maxExpiryInterval = 1 * time.Minute
 )
 
+// Node serves the HTTP API and syncs with its peers.
+// Nodes with a producer key also produce blocks.
+// stateLock guards the state which is shared by the
+// HTTP handlers, the sync and the producer, peersLock
+// guards the known peers. blockAdded is closed and
+// replaced under stateLock whenever blocks are added,
+// pendingTxnsChanged whenever txns are added to or
+// dropped from the mempool.
+// The relay workers push the txns of relayQueue to peers.
 type Node struct {
-	dataDir    string
-	ip         string
-	port       uint64
-	state      *database.State
-	knownPeers map[string]PeerNode
+	dataDir            string
+	ip                 string
+	port               uint64
+	state              *database.State
+	stateLock          sync.RWMutex
+	engine             consensus.Engine
+	knownPeers         map[string]PeerNode
+	peersLock          sync.RWMutex
+	producerKey        ed25519.PrivateKey
+	producerConfig     ProducerConfig
+	pendingTxnAdded    chan struct{}
+	blockAdded         chan struct{}
+	pendingTxnsChanged chan struct{}
+	finality           database.Finality
+	mempoolLimits      database.MempoolLimits
+	seenTxns           *seenTxns
+	relayQueue         chan relay
 }
 
-// BalanceRes stores the block hash and balances
+// BalanceRes stores the block hash, balances, next nonces
+// and the fees earned by block producers
 type BalancesRes struct {
-	Hash    database.Hash             `json:"block_hash"`
-	Balance map[database.Account]uint `json:"balances"`
+	Hash    database.Hash               `json:"block_hash"`
+	Balance map[database.Account]uint   `json:"balances"`
+	Nonces  map[database.Account]uint64 `json:"nonces"`
+	Fees    map[database.Account]uint   `json:"fees_earned"`
 }
 
 type PeerNode struct {
 	IP          string `json:"ip"`
 	Port        uint64 `json:"port"`
 	IsBootStrap bool   `json:"is_bootstrap"`
-	connected   bool   `json:"is_active"`
+	connected   bool
 }
 
 // NewPeerNode returns a new peer node
@@ -46,18 +96,71 @@ func NewPeerNode(ip string, port uint64, isbootstrap, isactive bool) *PeerNode {
 	return &PeerNode{ip, port, isbootstrap, isactive}
 }
 
-// New returns a new node
-func New(dataDir, ip string, port uint64, bootstrap PeerNode) *Node {
+// New returns a new node.
+// The node only produces blocks if producerKey is not nil.
+func New(dataDir, ip string, port uint64, producerKey ed25519.PrivateKey, producerConfig ProducerConfig, bootstrap PeerNode, finality database.Finality, mempoolLimits database.MempoolLimits) *Node {
 	knownPeers := make(map[string]PeerNode)
 	knownPeers[bootstrap.TcpAddress()] = bootstrap
 	return &Node{
-		dataDir:    dataDir,
-		ip:         ip,
-		port:       port,
-		knownPeers: knownPeers,
+		dataDir:            dataDir,
+		ip:                 ip,
+		port:               port,
+		knownPeers:         knownPeers,
+		producerKey:        producerKey,
+		producerConfig:     producerConfig,
+		pendingTxnAdded:    make(chan struct{}, 1),
+		blockAdded:         make(chan struct{}),
+		pendingTxnsChanged: make(chan struct{}),
+		finality:           finality,
+		mempoolLimits:      mempoolLimits,
+		seenTxns:           newSeenTxns(),
+		relayQueue:         make(chan relay, relayQueueSize),
+	}
+}
+
+// notifyBlockAdded wakes everyone waiting for new blocks.
+// It must be called while holding the state lock.
+func (n *Node) notifyBlockAdded() {
+	close(n.blockAdded)
+	n.blockAdded = make(chan struct{})
+}
+
+// notifyPendingTxnsChanged wakes everyone waiting for changes of the
+// mempool. It must be called while holding the state lock.
+func (n *Node) notifyPendingTxnsChanged() {
+	close(n.pendingTxnsChanged)
+	n.pendingTxnsChanged = make(chan struct{})
+}
+
+// expire drops the expired pending txns every expiry interval
+// until the context is done
+func (n *Node) expire(ctx context.Context) {
+	interval := n.mempoolLimits.Expiry
+	if interval > maxExpiryInterval {
+		interval = maxExpiryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.stateLock.Lock()
+			if n.state.ExpirePendingTxns(time.Now()) > 0 {
+				n.notifyPendingTxnsChanged()
+			}
+			n.stateLock.Unlock()
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
+// producer returns the account of the node's producer key
+func (n *Node) producer() database.Account {
+	return database.NewAccountFromPubKey(n.producerKey.Public().(ed25519.PublicKey))
+}
+
 // Run starts the HTTP server and APIs
 func (n *Node) Run() error {
 	ctx := context.Background()
@@ -68,22 +171,73 @@ func (n *Node) Run() error {
 	}
 	defer state.Close()
 
+	// refuse peers rewriting the blocks the operator trusts
+	if err := state.SetFinality(n.finality); err != nil {
+		return err
+	}
+	state.SetMempoolLimits(n.mempoolLimits)
+
+	fmt.Printf("Chain %s with genesis %x using the %s consensus engine\n", state.ChainID(), state.GenesisHash(), state.Consensus().Engine)
+	if n.producerKey != nil {
+		fmt.Printf("Producing blocks as %s\n", n.producer())
+	}
 	n.state = state
+	n.engine = state.Engine()
 
 	//sync peer lists and blocks every minute
 	go n.sync(ctx)
 
+	// relay the pending txns to peers
+	for i := 0; i < relayWorkers; i++ {
+		go n.relayTxns(ctx)
+	}
+
+	// drop expired txns while no txns are added
+	if n.mempoolLimits.Expiry > 0 {
+		go n.expire(ctx)
+	}
+
+	// pack the pending txns into blocks
+	if n.producerKey != nil {
+		go n.produce(ctx)
+	}
+
 	http.HandleFunc("/balances/list", func(w http.ResponseWriter, r *http.Request) {
+		n.stateLock.RLock()
+		defer n.stateLock.RUnlock()
 		listBalancesHandler(w, r, state)
 	})
-	http.HandleFunc("/txn/add", func(w http.ResponseWriter, r *http.Request) {
-		txnAddHandler(w, r, state)
+	http.HandleFunc(endpointBalance, func(w http.ResponseWriter, r *http.Request) {
+		n.stateLock.RLock()
+		defer n.stateLock.RUnlock()
+		balanceGetHandler(w, r, state)
+	})
+	http.HandleFunc(endpointTxnAdd, func(w http.ResponseWriter, r *http.Request) {
+		txnAddHandler(w, r, n)
+	})
+	http.HandleFunc(endpointTxn, func(w http.ResponseWriter, r *http.Request) {
+		// the status may wait for blocks and takes the lock itself
+		if strings.HasSuffix(r.URL.Path, endpointTxnStatus) {
+			txnStatusHandler(w, r, n)
+			return
+		}
+
+		n.stateLock.RLock()
+		defer n.stateLock.RUnlock()
+		txnGetHandler(w, r, state)
 	})
 	http.HandleFunc(endpointStatus, func(w http.ResponseWriter, r *http.Request) {
+		n.stateLock.RLock()
+		defer n.stateLock.RUnlock()
 		statusHandler(w, r, n)
 	})
 	http.HandleFunc(endpointSync, func(w http.ResponseWriter, r *http.Request) {
-		syncHandler(w, r, n.dataDir)
+		n.stateLock.RLock()
+		defer n.stateLock.RUnlock()
+		syncHandler(w, r, n.state)
+	})
+	http.HandleFunc(endpointTxnRelay, func(w http.ResponseWriter, r *http.Request) {
+		txnRelayHandler(w, r, n)
 	})
 	http.HandleFunc(endpointAddPeer, func(w http.ResponseWriter, r *http.Request) {
 		addPeerHandler(w, r, n)
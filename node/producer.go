@@ -0,0 +1,134 @@
+package node
+
+import (
+	"blockchain-sample/database"
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultProduceInterval is how often a producing node packs its pending txns into a block
+const DefaultProduceInterval = 10 * time.Second
+
+// ProducerConfig sets when a producing node packs its pending txns
+// into a block: every Interval, or as soon as BlockSize txns are
+// pending. A BlockSize of 0 waits until a block would be full.
+type ProducerConfig struct {
+	Interval  time.Duration
+	BlockSize int
+}
+
+// blockSize returns the number of pending txns which trigger a block,
+// leaving room in the block for the coinbase. Genesis allows at least
+// two txns in a block.
+func (n *Node) blockSize() int {
+	max := int(n.state.Consensus().MaxBlockTxns) - 1
+	if n.producerConfig.BlockSize > 0 && n.producerConfig.BlockSize < max {
+		return n.producerConfig.BlockSize
+	}
+	return max
+}
+
+// produce packs the pending txns into a block every producer
+// interval, or once enough txns are pending to reach the block size.
+// Without pending txns a block is only produced for its reward, so
+// that a new chain without balances mints its first coins.
+func (n *Node) produce(ctx context.Context) {
+	ticker := time.NewTicker(n.producerConfig.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-n.pendingTxnAdded:
+			n.stateLock.RLock()
+			count := n.state.PendingCount()
+			n.stateLock.RUnlock()
+
+			if count < n.blockSize() {
+				continue
+			}
+		case <-ctx.Done():
+			return
+		}
+
+		// expired txns are not packed even if no txn was added since
+		n.stateLock.Lock()
+		if n.state.ExpirePendingTxns(time.Now()) > 0 {
+			n.notifyPendingTxnsChanged()
+		}
+		txns := n.state.PendingTxns(n.blockSize())
+		reward := n.engine.Finalize(n.state, database.BlockHeader{Number: n.state.NextBlockNumber(), Producer: n.producer()})
+		n.stateLock.Unlock()
+
+		if len(txns) == 0 && reward == 0 {
+			continue
+		}
+
+		blockHash, err := n.produceBlock(ctx, txns)
+		if err != nil {
+			fmt.Println("[-] ", err)
+			continue
+		}
+		fmt.Printf("Produced block %x with %d pending txns\n", blockHash, len(txns))
+	}
+}
+
+// produceBlock adds a block with the txns on top of the latest block.
+// The engine prepares the header, the block waits until its time, with
+// proof of authority for the producer's turn, and is then sealed. The
+// block is abandoned once another block is added, its parent would no
+// longer be the latest block.
+func (n *Node) produceBlock(ctx context.Context, txns []database.SignedTxn) (database.Hash, error) {
+	n.stateLock.RLock()
+	pending, err := n.state.NewPendingBlock(n.producer(), uint64(time.Now().Unix()), txns)
+	blockAdded := n.blockAdded
+	n.stateLock.RUnlock()
+	if err != nil {
+		return database.Hash{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-blockAdded:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	block, err := n.sealBlock(ctx, pending)
+	if err != nil {
+		select {
+		case <-blockAdded:
+			return database.Hash{}, fmt.Errorf("abandoned block %d, another block was added while it was produced", pending.Header.Number)
+		default:
+			return database.Hash{}, err
+		}
+	}
+
+	// the chain may have moved on right after the block was sealed,
+	// the block is then kept as a side branch
+	n.stateLock.Lock()
+	defer n.stateLock.Unlock()
+	blockHash, err := n.state.AddBlock(block)
+	if err != nil {
+		return database.Hash{}, err
+	}
+	n.notifyBlockAdded()
+	return blockHash, nil
+}
+
+// sealBlock waits until the time of the pending block and seals it
+func (n *Node) sealBlock(ctx context.Context, pending database.Block) (database.Block, error) {
+	if wait := time.Until(time.Unix(int64(pending.Header.Time), 0)); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return database.Block{}, ctx.Err()
+		}
+	}
+
+	return n.engine.Seal(ctx, pending, n.producerKey)
+}
@@ -0,0 +1,45 @@
+package node
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProduceDefaultChain(t *testing.T) {
+	producerKey, producer := testKey("producer")
+	n := newTestNode(t, t.TempDir(), producerKey)
+
+	// the default genesis has no balances and so no txns can be sent
+	n.stateLock.RLock()
+	blockAdded := n.blockAdded
+	supply := n.state.Supply()
+	n.stateLock.RUnlock()
+	if supply != 0 {
+		t.Fatalf("default chain has a supply of %d, want 0", supply)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.produce(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitBlockAdded(t, blockAdded)
+
+	n.stateLock.RLock()
+	defer n.stateLock.RUnlock()
+
+	latest := n.state.LatestBlock()
+	if latest.Header.Number != 0 || latest.Header.Producer != producer {
+		t.Errorf("latest block is %d by %s, want block 0 by %s", latest.Header.Number, latest.Header.Producer, producer)
+	}
+	reward := n.state.Consensus().BlockReward
+	if balance := n.state.Balances[producer]; balance != reward || n.state.Supply() != reward {
+		t.Errorf("producer has %d of supply %d, want the reward %d", balance, n.state.Supply(), reward)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -25,7 +26,7 @@ func (n *Node) sync(ctx context.Context) error {
 }
 
 func (n *Node) doSync() {
-	for _, peer := range n.knownPeers {
+	for _, peer := range n.KnownPeers() {
 		if n.ip == peer.IP && n.port == peer.Port {
 			return
 		}
@@ -38,6 +39,14 @@ func (n *Node) doSync() {
 			fmt.Printf("[*] Peer %s was removed from known peers", peer.TcpAddress())
 			continue
 		}
+
+		// refuse peers of another chain or genesis
+		if status.ChainID != n.state.ChainID() || status.GenesisHash != n.state.GenesisHash() {
+			fmt.Printf("[-] Peer %s is on chain %q with genesis %x, not %q with genesis %x\n", peer.TcpAddress(), status.ChainID, status.GenesisHash, n.state.ChainID(), n.state.GenesisHash())
+			n.RemovePeer(peer)
+			fmt.Printf("[*] Peer %s was removed from known peers", peer.TcpAddress())
+			continue
+		}
 		err = n.joinKnownPeers(peer)
 		if err != nil {
 			fmt.Println("[-] ", err)
@@ -55,38 +64,40 @@ func (n *Node) doSync() {
 }
 
 func (n *Node) syncBlocks(peer PeerNode, status StatusRes) error {
-	localBlockNumber := n.state.LatestBlock().Header.Number
+	n.stateLock.RLock()
+	totalWork, latestBlockHash := n.state.TotalWork(), n.state.LatestBlockHash()
+	n.stateLock.RUnlock()
 
-	// check if peer has no blocks
-	if status.Hash.IsEmpty() {
+	// only a chain with more work than ours can replace it,
+	// the peer has no blocks if its latest hash is the genesis
+	if status.Hash == status.GenesisHash || status.TotalWork == nil || status.TotalWork.Cmp(totalWork) <= 0 {
 		return nil
 	}
 
-	// check if peer has lesser blocks than us
-	if status.Number < localBlockNumber {
-		return nil
-	}
-
-	// check if it is genesis block and we already synced it
-	if status.Number == 0 && !n.state.LatestBlockHash().IsEmpty() {
-		return nil
+	// the peer sends its blocks from the fork point
+	// if our latest block is not part of its chain
+	blocks, err := fetchBlocksFromPeer(peer, latestBlockHash)
+	if err != nil {
+		return err
 	}
 
-	newBlockCount := status.Number - localBlockNumber
+	fmt.Printf("Found a chain up to block %d with more work from %s, received %d blocks\n", status.Number, peer.TcpAddress(), len(blocks))
 
-	// display found 1 new block if we sync genesis block
-	if localBlockNumber == 0 && status.Number == 0 {
-		newBlockCount = 1
+	// refuse blocks of another chain before applying any of them
+	for _, block := range blocks {
+		if block.Header.ChainID != n.state.ChainID() {
+			return fmt.Errorf("peer %s sent block %d of chain %q", peer.TcpAddress(), block.Header.Number, block.Header.ChainID)
+		}
 	}
 
-	fmt.Printf("Found %d new blocks from %s\n", newBlockCount, peer.TcpAddress())
-
-	blocks, err := fetchBlocksFromPeer(peer, n.state.LatestBlockHash())
-	if err != nil {
-		return err
+	// blocks added before a rejected block are kept
+	n.stateLock.Lock()
+	defer n.stateLock.Unlock()
+	defer n.notifyBlockAdded()
+	if err := n.state.AddBlocks(blocks); err != nil {
+		return fmt.Errorf("rejected blocks from peer %s: %s", peer.TcpAddress(), err)
 	}
-
-	return n.state.AddBlocks(blocks)
+	return nil
 }
 
 func (n *Node) syncKnownPeers(peer PeerNode, status StatusRes) {
@@ -103,7 +114,7 @@ func (n *Node) joinKnownPeers(peer PeerNode) error {
 		return nil
 	}
 
-	url := fmt.Sprintf("http://%s%s?%s=%s&%s=%d", peer.TcpAddress(), endpointAddPeer, endpointAddPeerQueryKeyIP, n.ip, endpointAddPeerQueryKeyPort, n.port)
+	url := fmt.Sprintf("http://%s%s?%s=%s&%s=%d&%s=%s&%s=%x", peer.TcpAddress(), endpointAddPeer, endpointAddPeerQueryKeyIP, n.ip, endpointAddPeerQueryKeyPort, n.port, endpointAddPeerQueryKeyChainID, url.QueryEscape(n.state.ChainID()), endpointAddPeerQueryKeyGenesis, n.state.GenesisHash())
 
 	res, err := http.Get(url)
 	if err != nil {
@@ -116,7 +127,9 @@ func (n *Node) joinKnownPeers(peer PeerNode) error {
 		return fmt.Errorf(addPeerRes.Error)
 	}
 
+	n.peersLock.RLock()
 	knownPeer := n.knownPeers[peer.TcpAddress()]
+	n.peersLock.RUnlock()
 	knownPeer.connected = addPeerRes.Success
 
 	n.AddPeer(knownPeer)
@@ -163,19 +176,37 @@ func fetchBlocksFromPeer(peer PeerNode, fromBlock database.Hash) ([]database.Blo
 	return syncRes.Blocks, nil
 }
 func (n *Node) AddPeer(peer PeerNode) {
+	n.peersLock.Lock()
+	defer n.peersLock.Unlock()
 	n.knownPeers[peer.TcpAddress()] = peer
 }
 
 func (n *Node) RemovePeer(peer PeerNode) {
+	n.peersLock.Lock()
+	defer n.peersLock.Unlock()
 	delete(n.knownPeers, peer.TcpAddress())
 }
 
+// KnownPeers returns a copy of the known peers
+func (n *Node) KnownPeers() map[string]PeerNode {
+	n.peersLock.RLock()
+	defer n.peersLock.RUnlock()
+
+	peers := make(map[string]PeerNode, len(n.knownPeers))
+	for address, peer := range n.knownPeers {
+		peers[address] = peer
+	}
+	return peers
+}
+
 func (n *Node) IsKnownPeer(peer PeerNode) bool {
 	if peer.IP == n.ip && peer.Port == n.port {
 		return true
 	}
 
+	n.peersLock.RLock()
 	_, isKnownPeer := n.knownPeers[peer.TcpAddress()]
+	n.peersLock.RUnlock()
 
 	return isKnownPeer
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 )
 
@@ -13,24 +14,48 @@ type ErrRes struct {
 	Error string `json:"error"`
 }
 
-// StatusRes stores block hash and number
+// StatusRes stores the chain id, the genesis hash, the latest
+// block hash and number and the total work of the canonical chain
 type StatusRes struct {
-	Hash       database.Hash       `json:"block_hash"`
-	Number     uint64              `json:"block_number"`
-	KnownPeers map[string]PeerNode `json:"peers_known"`
+	ChainID     string              `json:"chain_id"`
+	GenesisHash database.Hash       `json:"genesis_hash"`
+	Hash        database.Hash       `json:"block_hash"`
+	Number      uint64              `json:"block_number"`
+	TotalWork   *big.Int            `json:"total_work"`
+	KnownPeers  map[string]PeerNode `json:"peers_known"`
 }
 
+// TxnAddReq stores a txn signed by the sender.
+// PubKey and Sig are hex encoded. Txns from a
+// multisig account carry Multisig and Sigs instead.
 type TxnAddReq struct {
-	From  string `json:"from"`
-	To    string `json:"to"`
-	Value uint   `json:"value"`
-	Data  string `json:"data"`
+	ChainID  string                    `json:"chain_id"`
+	From     string                    `json:"from"`
+	To       string                    `json:"to"`
+	Value    uint                      `json:"value"`
+	Fee      uint                      `json:"fee"`
+	Nonce    uint64                    `json:"nonce"`
+	Data     string                    `json:"data"`
+	PubKey   string                    `json:"pub_key"`
+	Sig      string                    `json:"signature"`
+	Multisig *database.MultisigAccount `json:"multisig"`
+	Sigs     []database.Signature      `json:"signatures"`
 }
 
+// TxnAddRes stores the hash of the pending txn
 type TxnAddRes struct {
 	Hash database.Hash `json:"hash"`
 }
 
+// TxnRes stores an included txn, where it was included
+// and the number of blocks confirming it
+type TxnRes struct {
+	Hash          database.Hash        `json:"hash"`
+	Txn           database.SignedTxn   `json:"txn"`
+	Location      database.TxnLocation `json:"location"`
+	Confirmations uint64               `json:"confirmations"`
+}
+
 type SyncRes struct {
 	Blocks []database.Block `json:"blocks"`
 }
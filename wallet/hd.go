@@ -0,0 +1,163 @@
+package wallet
+
+import (
+	"blockchain-sample/database"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	seedFileName = "hd.seed"
+
+	// mnemonics of 24 words
+	mnemonicEntropyBits = 256
+
+	// keys are derived following slip-0010 for ed25519,
+	// which only supports hardened derivation
+	slip10Curve    = "ed25519 seed"
+	hardenedOffset = 0x80000000
+	hdPurpose      = 44
+	hdCoinType     = 7777 // not registered in slip-0044
+	hdAccount      = 0
+	hdChange       = 0
+)
+
+// SeedFile stores the mnemonic of the keystore encrypted with a passphrase
+type SeedFile struct {
+	Crypto CryptoParams `json:"crypto"`
+}
+
+func getSeedFilePath(dataDir string) string {
+	return filepath.Join(GetKeystoreDirPath(dataDir), seedFileName)
+}
+
+// HDPath returns the derivation path of the key with the given index
+func HDPath(index uint32) string {
+	return fmt.Sprintf("m/%d'/%d'/%d'/%d'/%d'", hdPurpose, hdCoinType, hdAccount, hdChange, index)
+}
+
+// NewMnemonic returns a new random 24 word bip-39 mnemonic
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+// SetSeed validates the mnemonic and stores it encrypted with the passphrase.
+// A keystore holds a single seed which all derived accounts come from.
+func SetSeed(dataDir, mnemonic, passphrase string) error {
+	mnemonic = normalizeMnemonic(mnemonic)
+	if _, err := bip39.NewSeedWithErrorChecking(mnemonic, ""); err != nil {
+		return fmt.Errorf("invalid mnemonic: %s", err)
+	}
+
+	if exists(getSeedFilePath(dataDir)) {
+		return fmt.Errorf("keystore %s already has a seed", GetKeystoreDirPath(dataDir))
+	}
+
+	crypto, err := encrypt([]byte(mnemonic), []byte(seedFileName), passphrase)
+	if err != nil {
+		return err
+	}
+
+	seedFileJson, err := json.MarshalIndent(SeedFile{crypto}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(dataDir, getSeedFilePath(dataDir), seedFileJson)
+}
+
+// DeriveAccount derives the key with the given index from the seed and
+// stores it in the keystore encrypted with the passphrase of the seed
+func DeriveAccount(dataDir string, index uint32, passphrase string) (database.Account, error) {
+	mnemonic, err := loadMnemonic(dataDir, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	privKey, err := DeriveKey(mnemonic, index)
+	if err != nil {
+		return "", err
+	}
+
+	return importKey(dataDir, privKey, passphrase, &index)
+}
+
+// DeriveKey derives the key with the given index from the mnemonic
+func DeriveKey(mnemonic string, index uint32) (ed25519.PrivateKey, error) {
+	if index >= hardenedOffset {
+		return nil, fmt.Errorf("index must be lower than %d", uint32(hardenedOffset))
+	}
+
+	seed, err := bip39.NewSeedWithErrorChecking(normalizeMnemonic(mnemonic), "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %s", err)
+	}
+
+	key := deriveSlip10(seed, []uint32{hdPurpose, hdCoinType, hdAccount, hdChange, index})
+	return ed25519.NewKeyFromSeed(key), nil
+}
+
+// deriveSlip10 derives the ed25519 key at the hardened path from the seed
+func deriveSlip10(seed []byte, path []uint32) []byte {
+	// master key
+	mac := hmac.New(sha512.New, []byte(slip10Curve))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := sum[:32], sum[32:]
+
+	// hardened child keys along the path
+	for _, i := range path {
+		data := make([]byte, 37)
+		copy(data[1:33], key)
+		binary.BigEndian.PutUint32(data[33:], i+hardenedOffset)
+
+		mac = hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum = mac.Sum(nil)
+		key, chainCode = sum[:32], sum[32:]
+	}
+
+	return key
+}
+
+func loadMnemonic(dataDir, passphrase string) (string, error) {
+	content, err := ioutil.ReadFile(getSeedFilePath(dataDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("keystore %s has no seed", GetKeystoreDirPath(dataDir))
+		}
+		return "", err
+	}
+
+	var seedFile SeedFile
+	if err := json.Unmarshal(content, &seedFile); err != nil {
+		return "", err
+	}
+
+	mnemonic, err := decrypt(seedFile.Crypto, []byte(seedFileName), passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	return string(mnemonic), nil
+}
+
+// normalizeMnemonic lower cases the words and separates them by single spaces
+func normalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+}
@@ -0,0 +1,68 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeriveSlip10(t *testing.T) {
+	// slip-0010 test vector 1 for ed25519
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	tests := []struct {
+		path []uint32
+		key  string
+	}{
+		{[]uint32{}, "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7"},
+		{[]uint32{0}, "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"},
+		{[]uint32{0, 1}, "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2"},
+		{[]uint32{0, 1, 2}, "92a5b23c0b8a99e37d07df3fb9966917f5d06e02ddbd909c7e184371463e9fc9"},
+		{[]uint32{0, 1, 2, 2}, "30d1dc7e5fc04c31219ab25a27ae00b50f6fd66622f6e9c913253d6511d1e662"},
+		{[]uint32{0, 1, 2, 2, 1000000000}, "8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793"},
+	}
+
+	for _, tt := range tests {
+		if key := hex.EncodeToString(deriveSlip10(seed, tt.path)); key != tt.key {
+			t.Errorf("path %v: got key %s, want %s", tt.path, key, tt.key)
+		}
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	key, err := DeriveKey(mnemonic, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		mnemonic string
+		index    uint32
+		same     bool
+		err      bool
+	}{
+		{"same index", mnemonic, 0, true, false},
+		{"unnormalized mnemonic", "  Abandon abandon abandon abandon abandon abandon\tabandon abandon abandon abandon abandon ABOUT ", 0, true, false},
+		{"other index", mnemonic, 1, false, false},
+		{"hardened index", mnemonic, hardenedOffset, false, true},
+		{"invalid checksum", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", 0, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			derived, err := DeriveKey(tt.mnemonic, tt.index)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %t", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if same := derived.Equal(key); same != tt.same {
+				t.Errorf("got same key %t, want %t", same, tt.same)
+			}
+		})
+	}
+}
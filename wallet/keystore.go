@@ -0,0 +1,289 @@
+package wallet
+
+import (
+	"blockchain-sample/database"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keystoreDirName = "keystore"
+	keyFileExt      = ".json"
+
+	kdfScrypt    = "scrypt"
+	cipherAesGcm = "aes-256-gcm"
+
+	// scrypt parameters recommended for interactive logins
+	scryptN     = 1 << 15
+	scryptR     = 8
+	scryptP     = 1
+	scryptDKLen = 32
+)
+
+// KeyFile stores a private key encrypted with a passphrase.
+// The public key is stored in plain text so it can be
+// shared, e.g. to set up a multisig account.
+// HDIndex is set for keys derived from the keystore seed.
+type KeyFile struct {
+	Account database.Account `json:"account"`
+	PubKey  database.Bytes   `json:"pub_key"`
+	HDIndex *uint32          `json:"hd_index,omitempty"`
+	Crypto  CryptoParams     `json:"crypto"`
+}
+
+// CryptoParams stores everything needed to decrypt a key except the passphrase
+type CryptoParams struct {
+	KDF        string         `json:"kdf"`
+	KDFParams  ScryptParams   `json:"kdf_params"`
+	Cipher     string         `json:"cipher"`
+	Nonce      database.Bytes `json:"nonce"`
+	CipherText database.Bytes `json:"cipher_text"`
+}
+
+// ScryptParams stores the scrypt cost parameters and salt
+type ScryptParams struct {
+	N     int            `json:"n"`
+	R     int            `json:"r"`
+	P     int            `json:"p"`
+	DKLen int            `json:"dk_len"`
+	Salt  database.Bytes `json:"salt"`
+}
+
+// GetKeystoreDirPath returns the directory holding the key files
+func GetKeystoreDirPath(dataDir string) string {
+	return filepath.Join(dataDir, keystoreDirName)
+}
+
+func getKeyFilePath(dataDir string, account database.Account) string {
+	return filepath.Join(GetKeystoreDirPath(dataDir), string(account)+keyFileExt)
+}
+
+// NewKeystoreAccount generates a new key, stores it encrypted
+// with the passphrase and returns the account it owns
+func NewKeystoreAccount(dataDir, passphrase string) (database.Account, error) {
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	return ImportKey(dataDir, privKey, passphrase)
+}
+
+// ImportKey stores the given private key encrypted with the passphrase
+func ImportKey(dataDir string, privKey ed25519.PrivateKey, passphrase string) (database.Account, error) {
+	return importKey(dataDir, privKey, passphrase, nil)
+}
+
+func importKey(dataDir string, privKey ed25519.PrivateKey, passphrase string, hdIndex *uint32) (database.Account, error) {
+	pubKey := privKey.Public().(ed25519.PublicKey)
+	account := database.NewAccountFromPubKey(pubKey)
+	if exists(getKeyFilePath(dataDir, account)) {
+		return "", fmt.Errorf("account %s already exists in keystore", account)
+	}
+
+	// the account is authenticated along with the key
+	// so a key file cannot be renamed to another account
+	crypto, err := encrypt(privKey.Seed(), []byte(account), passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	keyFile := KeyFile{account, database.Bytes(pubKey), hdIndex, crypto}
+	if err := writeKeyFile(dataDir, keyFile); err != nil {
+		return "", err
+	}
+
+	return account, nil
+}
+
+// LoadKey decrypts the private key of the account with the passphrase
+func LoadKey(dataDir string, account database.Account, passphrase string) (ed25519.PrivateKey, error) {
+	keyFile, err := readKeyFile(dataDir, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptKey(keyFile, passphrase)
+}
+
+// GetKeyFile returns the key file of the account without decrypting its key
+func GetKeyFile(dataDir string, account database.Account) (KeyFile, error) {
+	return readKeyFile(dataDir, account)
+}
+
+func readKeyFile(dataDir string, account database.Account) (KeyFile, error) {
+	content, err := ioutil.ReadFile(getKeyFilePath(dataDir, account))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return KeyFile{}, fmt.Errorf("account %s not found in keystore", account)
+		}
+		return KeyFile{}, err
+	}
+
+	var keyFile KeyFile
+	if err := json.Unmarshal(content, &keyFile); err != nil {
+		return KeyFile{}, err
+	}
+
+	return keyFile, nil
+}
+
+// ChangePassphrase re-encrypts the key of the account with a new passphrase
+func ChangePassphrase(dataDir string, account database.Account, oldPassphrase, newPassphrase string) error {
+	keyFile, err := readKeyFile(dataDir, account)
+	if err != nil {
+		return err
+	}
+
+	privKey, err := decryptKey(keyFile, oldPassphrase)
+	if err != nil {
+		return err
+	}
+
+	keyFile.Crypto, err = encrypt(privKey.Seed(), []byte(account), newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	return writeKeyFile(dataDir, keyFile)
+}
+
+// ListAccounts returns all accounts stored in the keystore
+func ListAccounts(dataDir string) ([]database.Account, error) {
+	files, err := ioutil.ReadDir(GetKeystoreDirPath(dataDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []database.Account{}, nil
+		}
+		return nil, err
+	}
+
+	accounts := make([]database.Account, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != keyFileExt {
+			continue
+		}
+		accounts = append(accounts, database.Account(strings.TrimSuffix(f.Name(), keyFileExt)))
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i] < accounts[j] })
+
+	return accounts, nil
+}
+
+// writeKeyFile writes the key file to the keystore
+func writeKeyFile(dataDir string, keyFile KeyFile) error {
+	keyFileJson, err := json.MarshalIndent(keyFile, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(dataDir, getKeyFilePath(dataDir, keyFile.Account), keyFileJson)
+}
+
+// writeFileAtomic writes the content to a path in the keystore.
+// The file is written to a temporary path first so that an existing
+// key is never left half written.
+func writeFileAtomic(dataDir, path string, content []byte) error {
+	if err := os.MkdirAll(GetKeystoreDirPath(dataDir), 0700); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path+".tmp", content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// decryptKey decrypts the private key stored in the key file
+func decryptKey(keyFile KeyFile, passphrase string) (ed25519.PrivateKey, error) {
+	seed, err := decrypt(keyFile.Crypto, []byte(keyFile.Account), passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid key length %d", len(seed))
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// encrypt encrypts the plain text with aes-256-gcm using a key
+// derived from the passphrase by scrypt. The additional data
+// is authenticated but not encrypted.
+func encrypt(plainText, additionalData []byte, passphrase string) (CryptoParams, error) {
+	params := ScryptParams{scryptN, scryptR, scryptP, scryptDKLen, make([]byte, 32)}
+	if _, err := rand.Read(params.Salt); err != nil {
+		return CryptoParams{}, err
+	}
+
+	aead, err := newAead(passphrase, params)
+	if err != nil {
+		return CryptoParams{}, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return CryptoParams{}, err
+	}
+
+	cipherText := aead.Seal(nil, nonce, plainText, additionalData)
+
+	return CryptoParams{kdfScrypt, params, cipherAesGcm, nonce, cipherText}, nil
+}
+
+// decrypt decrypts the cipher text encrypted by encrypt
+func decrypt(params CryptoParams, additionalData []byte, passphrase string) ([]byte, error) {
+	if params.KDF != kdfScrypt || params.Cipher != cipherAesGcm {
+		return nil, fmt.Errorf("unsupported kdf %q or cipher %q", params.KDF, params.Cipher)
+	}
+
+	aead, err := newAead(passphrase, params.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce")
+	}
+
+	plainText, err := aead.Open(nil, params.Nonce, params.CipherText, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt with given passphrase")
+	}
+
+	return plainText, nil
+}
+
+func newAead(passphrase string, params ScryptParams) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), params.Salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func exists(path string) bool {
+	if _, err := os.Stat(path); err != nil && os.IsNotExist(err) {
+		return false
+	}
+	return true
+}